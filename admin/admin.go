@@ -1,6 +1,7 @@
 package admin
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 
@@ -21,20 +22,69 @@ type AdminAPI interface {
 	ListHandles(sessionID uint64) (interface{}, error)
 	HandleInfo(sessionID, handleID uint64) (interface{}, error)
 
+	DestroySession(sessionID uint64) (*DestroySessionResponse, error)
+	DetachHandle(sessionID, handleID uint64) (*DetachHandleResponse, error)
+	HangupWebRTC(sessionID, handleID uint64) (*HangupWebRTCResponse, error)
+
+	StartPcap(sessionID, handleID uint64, folder, filename string, truncate int) (*CaptureResponse, error)
+	StartText2Pcap(sessionID, handleID uint64, folder, filename string, truncate int) (*CaptureResponse, error)
+	StopPcap(sessionID, handleID uint64) (*CaptureResponse, error)
+	StopText2Pcap(sessionID, handleID uint64) (*CaptureResponse, error)
+
+	Info() (*InfoResponse, error)
+	Ping() (*PingResponse, error)
+	GetStatus() (*GetStatusResponse, error)
+
+	SetSessionTimeout(timeout int) (*SessionTimeoutResponse, error)
+	SetLogLevel(level int) (*LogLevelResponse, error)
+	SetLogTimestamps(timestamps bool) (*LogTimestampsResponse, error)
+	SetLogColors(colors bool) (*LogColorsResponse, error)
+	SetLockingDebug(debug bool) (*LockingDebugResponse, error)
+	SetRefcountDebug(debug bool) (*RefcountDebugResponse, error)
+	SetLibniceDebug(debug bool) (*LibniceDebugResponse, error)
+	SetMinNackQueue(queue int) (*MinNackQueueResponse, error)
+	SetNoMediaTimer(timer int) (*NoMediaTimerResponse, error)
+	SetSlowlinkThreshold(threshold int) (*SlowlinkThresholdResponse, error)
+	AcceptNewSessions(accept bool) (*AcceptNewSessionsResponse, error)
+
 	Close() error
 }
 
 type DefaultAdminAPI struct {
 	transport Transport
 	secret    string
+	logger    janus.Logger
+	recorder  *janus.Recorder
 }
 
-func NewAdminAPI(url, secret string) (*DefaultAdminAPI, error) {
-	api := new(DefaultAdminAPI)
-	api.secret = secret
+// Option configures optional behaviour of a DefaultAdminAPI.
+type Option func(*DefaultAdminAPI)
+
+// WithLogger sets the Logger receiving diagnostic messages of the admin API
+// client. Defaults to janus.NopLogger.
+func WithLogger(logger janus.Logger) Option {
+	return func(api *DefaultAdminAPI) {
+		if logger != nil {
+			api.logger = logger
+		}
+	}
+}
+
+func NewAdminAPI(url, secret string, opts ...Option) (*DefaultAdminAPI, error) {
+	api := newAdminAPI(secret, opts)
 
 	if strings.HasPrefix(url, "http") {
-		api.transport = NewHttpTransport(url)
+		t := NewHttpTransport(url)
+		t.rec = api.recorder
+		api.transport = t
+	} else if strings.HasPrefix(url, "ws") {
+		t := NewWsTransport(url)
+		t.rec = api.recorder
+		api.transport = t
+	} else if strings.HasPrefix(url, "unix://") {
+		t := NewUnixTransport(strings.TrimPrefix(url, "unix://"))
+		t.rec = api.recorder
+		api.transport = t
 	} else {
 		return nil, fmt.Errorf("unsupported transport for %s", url)
 	}
@@ -42,46 +92,329 @@ func NewAdminAPI(url, secret string) (*DefaultAdminAPI, error) {
 	return api, nil
 }
 
+// NewAdminAPITransport returns an admin API client sending its requests
+// through transport, such as a ReplayTransport.
+func NewAdminAPITransport(transport Transport, secret string, opts ...Option) *DefaultAdminAPI {
+	api := newAdminAPI(secret, opts)
+	api.transport = transport
+	return api
+}
+
+func newAdminAPI(secret string, opts []Option) *DefaultAdminAPI {
+	api := new(DefaultAdminAPI)
+	api.secret = secret
+	api.logger = janus.NopLogger{}
+	for _, opt := range opts {
+		opt(api)
+	}
+	return api
+}
+
 func (api *DefaultAdminAPI) AddToken(token string, plugins []string) (interface{}, error) {
-	return api.transport.Request(api.makeTokenRequest("add_token", token, plugins))
+	return api.request(api.makeTokenRequest("add_token", token, plugins))
 }
 
 func (api *DefaultAdminAPI) AllowToken(token string, plugins []string) (interface{}, error) {
-	return api.transport.Request(api.makeTokenRequest("allow_token", token, plugins))
+	return api.request(api.makeTokenRequest("allow_token", token, plugins))
 }
 
 func (api *DefaultAdminAPI) DisallowToken(token string, plugins []string) (interface{}, error) {
-	return api.transport.Request(api.makeTokenRequest("disallow_token", token, plugins))
+	return api.request(api.makeTokenRequest("disallow_token", token, plugins))
 }
 
 func (api *DefaultAdminAPI) RemoveToken(token string) (interface{}, error) {
-	return api.transport.Request(api.makeTokenRequest("remove_token", token, nil))
+	return api.request(api.makeTokenRequest("remove_token", token, nil))
 }
 
 func (api *DefaultAdminAPI) ListTokens() (interface{}, error) {
-	return api.transport.Request(api.makeBaseRequest("list_tokens"))
+	return api.request(api.makeBaseRequest("list_tokens"))
 }
 
 func (api *DefaultAdminAPI) ListSessions() (interface{}, error) {
-	return api.transport.Request(api.makeBaseRequest("list_sessions"))
+	return api.request(api.makeBaseRequest("list_sessions"))
 }
 
 func (api *DefaultAdminAPI) MessagePlugin(request plugins.PluginRequest) (interface{}, error) {
-	return api.transport.Request(api.makeMessagePluginRequest(request))
+	return api.request(api.makeMessagePluginRequest(request))
 }
 
 func (api *DefaultAdminAPI) ListHandles(sessionID uint64) (interface{}, error) {
-	return api.transport.Request(api.makeSessionRequest("list_handles", sessionID))
+	return api.request(api.makeSessionRequest("list_handles", sessionID))
 }
 
 func (api *DefaultAdminAPI) HandleInfo(sessionID, handleID uint64) (interface{}, error) {
-	return api.transport.Request(api.makeHandleRequest("handle_info", sessionID, handleID))
+	return api.request(api.makeHandleRequest("handle_info", sessionID, handleID))
+}
+
+// DestroySession destroys a session and its handles, as if the client had
+// sent a destroy request. It fails with a *GoneError if the session is gone.
+func (api *DefaultAdminAPI) DestroySession(sessionID uint64) (*DestroySessionResponse, error) {
+	resp, err := api.request(api.makeSessionRequest("destroy_session", sessionID))
+	if err != nil {
+		return nil, gone(err, sessionID, 0)
+	}
+	destroyed, ok := resp.(*DestroySessionResponse)
+	if !ok {
+		return nil, unexpected("destroy_session", resp)
+	}
+	destroyed.SessionID = sessionID
+	return destroyed, nil
+}
+
+// DetachHandle detaches a handle from its plugin, as if the client had sent
+// a detach request. It fails with a *GoneError if the session or handle is
+// gone.
+func (api *DefaultAdminAPI) DetachHandle(sessionID, handleID uint64) (*DetachHandleResponse, error) {
+	resp, err := api.request(api.makeHandleRequest("detach_handle", sessionID, handleID))
+	if err != nil {
+		return nil, gone(err, sessionID, handleID)
+	}
+	detached, ok := resp.(*DetachHandleResponse)
+	if !ok {
+		return nil, unexpected("detach_handle", resp)
+	}
+	detached.SessionID, detached.HandleID = sessionID, handleID
+	return detached, nil
+}
+
+// HangupWebRTC closes the PeerConnection of a handle, which stays attached.
+// It fails with a *GoneError if the session or handle is gone.
+func (api *DefaultAdminAPI) HangupWebRTC(sessionID, handleID uint64) (*HangupWebRTCResponse, error) {
+	resp, err := api.request(api.makeHandleRequest("hangup_webrtc", sessionID, handleID))
+	if err != nil {
+		return nil, gone(err, sessionID, handleID)
+	}
+	hungup, ok := resp.(*HangupWebRTCResponse)
+	if !ok {
+		return nil, unexpected("hangup_webrtc", resp)
+	}
+	hungup.SessionID, hungup.HandleID = sessionID, handleID
+	return hungup, nil
+}
+
+// StartPcap makes the server dump the traffic of a handle to the pcap file
+// filename in folder, both on the server. Packets are truncated to truncate
+// bytes unless it is 0. Janus picks a name if filename is empty. It fails
+// with a *GoneError if the session or handle is gone.
+func (api *DefaultAdminAPI) StartPcap(sessionID, handleID uint64, folder, filename string, truncate int) (*CaptureResponse, error) {
+	return api.capture(api.makeCaptureRequest("start_pcap", sessionID, handleID, folder, filename, truncate), sessionID, handleID)
+}
+
+// StartText2Pcap is like StartPcap but dumps the traffic in the text format
+// of text2pcap, which is more robust to a crash of the server.
+func (api *DefaultAdminAPI) StartText2Pcap(sessionID, handleID uint64, folder, filename string, truncate int) (*CaptureResponse, error) {
+	return api.capture(api.makeCaptureRequest("start_text2pcap", sessionID, handleID, folder, filename, truncate), sessionID, handleID)
+}
+
+// StopPcap stops the capture started by StartPcap.
+func (api *DefaultAdminAPI) StopPcap(sessionID, handleID uint64) (*CaptureResponse, error) {
+	return api.capture(api.makeHandleRequest("stop_pcap", sessionID, handleID), sessionID, handleID)
+}
+
+// StopText2Pcap stops the capture started by StartText2Pcap.
+func (api *DefaultAdminAPI) StopText2Pcap(sessionID, handleID uint64) (*CaptureResponse, error) {
+	return api.capture(api.makeHandleRequest("stop_text2pcap", sessionID, handleID), sessionID, handleID)
+}
+
+func (api *DefaultAdminAPI) capture(r APIRequest, sessionID, handleID uint64) (*CaptureResponse, error) {
+	resp, err := api.request(r)
+	if err != nil {
+		return nil, gone(err, sessionID, handleID)
+	}
+	capture, ok := resp.(*CaptureResponse)
+	if !ok {
+		return nil, unexpected(r.ActionName(), resp)
+	}
+	capture.SessionID, capture.HandleID = sessionID, handleID
+	return capture, nil
+}
+
+func (api *DefaultAdminAPI) Info() (*InfoResponse, error) {
+	resp, err := api.request(api.makeBaseRequest("info"))
+	if err != nil {
+		return nil, err
+	}
+	if info, ok := resp.(*InfoResponse); ok {
+		return info, nil
+	}
+	return nil, unexpected("info", resp)
+}
+
+func (api *DefaultAdminAPI) Ping() (*PingResponse, error) {
+	resp, err := api.request(api.makeBaseRequest("ping"))
+	if err != nil {
+		return nil, err
+	}
+	if pong, ok := resp.(*PingResponse); ok {
+		return pong, nil
+	}
+	return nil, unexpected("ping", resp)
+}
+
+func (api *DefaultAdminAPI) GetStatus() (*GetStatusResponse, error) {
+	resp, err := api.request(api.makeBaseRequest("get_status"))
+	if err != nil {
+		return nil, err
+	}
+	if status, ok := resp.(*GetStatusResponse); ok {
+		return status, nil
+	}
+	return nil, unexpected("get_status", resp)
+}
+
+// SetSessionTimeout sets the number of seconds after which sessions without
+// keep-alives time out, 0 disables the timeout.
+func (api *DefaultAdminAPI) SetSessionTimeout(timeout int) (*SessionTimeoutResponse, error) {
+	resp, err := api.request(api.makeSettingRequest("set_session_timeout", "timeout", timeout))
+	if err != nil {
+		return nil, err
+	}
+	if setting, ok := resp.(*SessionTimeoutResponse); ok {
+		return setting, nil
+	}
+	return nil, unexpected("set_session_timeout", resp)
+}
+
+// SetLogLevel sets the log level of the server, from 0 (none) to 7 (huge).
+func (api *DefaultAdminAPI) SetLogLevel(level int) (*LogLevelResponse, error) {
+	resp, err := api.request(api.makeSettingRequest("set_log_level", "level", level))
+	if err != nil {
+		return nil, err
+	}
+	if setting, ok := resp.(*LogLevelResponse); ok {
+		return setting, nil
+	}
+	return nil, unexpected("set_log_level", resp)
+}
+
+func (api *DefaultAdminAPI) SetLogTimestamps(timestamps bool) (*LogTimestampsResponse, error) {
+	resp, err := api.request(api.makeSettingRequest("set_log_timestamps", "timestamps", timestamps))
+	if err != nil {
+		return nil, err
+	}
+	if setting, ok := resp.(*LogTimestampsResponse); ok {
+		return setting, nil
+	}
+	return nil, unexpected("set_log_timestamps", resp)
+}
+
+func (api *DefaultAdminAPI) SetLogColors(colors bool) (*LogColorsResponse, error) {
+	resp, err := api.request(api.makeSettingRequest("set_log_colors", "colors", colors))
+	if err != nil {
+		return nil, err
+	}
+	if setting, ok := resp.(*LogColorsResponse); ok {
+		return setting, nil
+	}
+	return nil, unexpected("set_log_colors", resp)
+}
+
+func (api *DefaultAdminAPI) SetLockingDebug(debug bool) (*LockingDebugResponse, error) {
+	resp, err := api.request(api.makeSettingRequest("set_locking_debug", "debug", debug))
+	if err != nil {
+		return nil, err
+	}
+	if setting, ok := resp.(*LockingDebugResponse); ok {
+		return setting, nil
+	}
+	return nil, unexpected("set_locking_debug", resp)
+}
+
+func (api *DefaultAdminAPI) SetRefcountDebug(debug bool) (*RefcountDebugResponse, error) {
+	resp, err := api.request(api.makeSettingRequest("set_refcount_debug", "debug", debug))
+	if err != nil {
+		return nil, err
+	}
+	if setting, ok := resp.(*RefcountDebugResponse); ok {
+		return setting, nil
+	}
+	return nil, unexpected("set_refcount_debug", resp)
+}
+
+func (api *DefaultAdminAPI) SetLibniceDebug(debug bool) (*LibniceDebugResponse, error) {
+	resp, err := api.request(api.makeSettingRequest("set_libnice_debug", "debug", debug))
+	if err != nil {
+		return nil, err
+	}
+	if setting, ok := resp.(*LibniceDebugResponse); ok {
+		return setting, nil
+	}
+	return nil, unexpected("set_libnice_debug", resp)
+}
+
+// SetMinNackQueue sets the minimum size in milliseconds of the NACK queue of
+// the PeerConnections.
+func (api *DefaultAdminAPI) SetMinNackQueue(queue int) (*MinNackQueueResponse, error) {
+	resp, err := api.request(api.makeSettingRequest("set_min_nack_queue", "min_nack_queue", queue))
+	if err != nil {
+		return nil, err
+	}
+	if setting, ok := resp.(*MinNackQueueResponse); ok {
+		return setting, nil
+	}
+	return nil, unexpected("set_min_nack_queue", resp)
+}
+
+// SetNoMediaTimer sets the number of seconds without media after which a
+// PeerConnection is reported as not receiving media.
+func (api *DefaultAdminAPI) SetNoMediaTimer(timer int) (*NoMediaTimerResponse, error) {
+	resp, err := api.request(api.makeSettingRequest("set_no_media_timer", "no_media_timer", timer))
+	if err != nil {
+		return nil, err
+	}
+	if setting, ok := resp.(*NoMediaTimerResponse); ok {
+		return setting, nil
+	}
+	return nil, unexpected("set_no_media_timer", resp)
+}
+
+// SetSlowlinkThreshold sets the number of lost packets per second after
+// which a slowlink event is sent, 0 disables slowlink events.
+func (api *DefaultAdminAPI) SetSlowlinkThreshold(threshold int) (*SlowlinkThresholdResponse, error) {
+	resp, err := api.request(api.makeSettingRequest("set_slowlink_threshold", "slowlink_threshold", threshold))
+	if err != nil {
+		return nil, err
+	}
+	if setting, ok := resp.(*SlowlinkThresholdResponse); ok {
+		return setting, nil
+	}
+	return nil, unexpected("set_slowlink_threshold", resp)
+}
+
+// AcceptNewSessions makes the server accept or refuse create requests.
+// Refused requests fail with janus.ErrNotAcceptingSessions.
+func (api *DefaultAdminAPI) AcceptNewSessions(accept bool) (*AcceptNewSessionsResponse, error) {
+	resp, err := api.request(api.makeSettingRequest("accept_new_sessions", "accept", accept))
+	if err != nil {
+		return nil, err
+	}
+	if setting, ok := resp.(*AcceptNewSessionsResponse); ok {
+		return setting, nil
+	}
+	return nil, unexpected("accept_new_sessions", resp)
 }
 
 func (api *DefaultAdminAPI) Close() error {
 	return api.transport.Close()
 }
 
+func (api *DefaultAdminAPI) request(r APIRequest) (interface{}, error) {
+	payload := r.Payload()
+	keyvals := []interface{}{"janus", r.ActionName(), "transaction", payload["transaction"]}
+	for _, key := range []string{"session_id", "handle_id"} {
+		if id, ok := payload[key]; ok {
+			keyvals = append(keyvals, key, id)
+		}
+	}
+
+	api.logger.Debug("sending admin request", keyvals...)
+	resp, err := api.transport.Request(r)
+	if err != nil {
+		api.logger.Warn("admin request failed", append(keyvals, "error", err)...)
+	}
+	return resp, err
+}
+
 func (api *DefaultAdminAPI) makeBaseRequest(action string) *BaseRequest {
 	return &BaseRequest{
 		Action:      action,
@@ -90,6 +423,23 @@ func (api *DefaultAdminAPI) makeBaseRequest(action string) *BaseRequest {
 	}
 }
 
+func (api *DefaultAdminAPI) makeCaptureRequest(action string, sessionID, handleID uint64, folder, filename string, truncate int) *CaptureRequest {
+	return &CaptureRequest{
+		HandleRequest: *api.makeHandleRequest(action, sessionID, handleID),
+		Folder:        folder,
+		Filename:      filename,
+		Truncate:      truncate,
+	}
+}
+
+func (api *DefaultAdminAPI) makeSettingRequest(action, name string, value interface{}) *SettingRequest {
+	return &SettingRequest{
+		BaseRequest: *api.makeBaseRequest(action),
+		Name:        name,
+		Value:       value,
+	}
+}
+
 func (api *DefaultAdminAPI) makeTokenRequest(action, token string, plugins []string) *TokenRequest {
 	return &TokenRequest{
 		BaseRequest: *api.makeBaseRequest(action),
@@ -118,3 +468,20 @@ func (api *DefaultAdminAPI) makeHandleRequest(action string, sessionID, handleID
 		HandleID:       handleID,
 	}
 }
+
+// unexpected returns the error of a response of the wrong type.
+func unexpected(action string, resp interface{}) error {
+	return fmt.Errorf("unexpected response to '%s' request: %T", action, resp)
+}
+
+// gone returns a *GoneError wrapping err if the server reports the session
+// or handle as not found, err otherwise.
+func gone(err error, sessionID, handleID uint64) error {
+	switch {
+	case errors.Is(err, janus.ErrSessionNotFound):
+		return &GoneError{SessionID: sessionID, Err: err}
+	case errors.Is(err, janus.ErrHandleNotFound):
+		return &GoneError{SessionID: sessionID, HandleID: handleID, Err: err}
+	}
+	return err
+}
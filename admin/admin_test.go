@@ -1,15 +1,22 @@
 package admin
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/timsolov/janus-go"
+	"github.com/timsolov/janus-go/janustest"
 	"github.com/timsolov/janus-go/plugins"
 )
 
 func TestAdminTokens(t *testing.T) {
-	api, err := NewAdminAPI("http://localhost:7088/admin", "janus-go")
+	server := newServer()
+	defer server.Close()
+
+	api, err := NewAdminAPI(server.AdminURL, "janus-go")
 	noError(t, err)
 
 	resp, err := api.AddToken("test-token", []string{"janus.plugin.videoroom"})
@@ -88,11 +95,14 @@ func findToken(t *testing.T, api AdminAPI, token string) *StoredToken {
 }
 
 func TestDefaultAdminAPI_ListSessions(t *testing.T) {
-	client, err := janus.Connect("ws://localhost:8188/")
+	server := newServer()
+	defer server.Close()
+
+	client, err := janus.Connect(server.URL)
 	noError(t, err)
 	defer client.Close()
 
-	api, err := NewAdminAPI("http://localhost:7088/admin", "janus-go")
+	api, err := NewAdminAPI(server.AdminURL, "janus-go")
 	noError(t, err)
 
 	_, err = api.AddToken("test-token", []string{})
@@ -124,11 +134,14 @@ func TestDefaultAdminAPI_ListSessions(t *testing.T) {
 }
 
 func TestDefaultAdminAPI_MessagePlugin_Videoroom(t *testing.T) {
-	client, err := janus.Connect("ws://localhost:8188/")
+	server := newServer()
+	defer server.Close()
+
+	client, err := janus.Connect(server.URL)
 	noError(t, err)
 	defer client.Close()
 
-	api, err := NewAdminAPI("http://localhost:7088/admin", "janus-go")
+	api, err := NewAdminAPI(server.AdminURL, "janus-go")
 	noError(t, err)
 
 	_, err = api.AddToken("test-token", []string{})
@@ -265,11 +278,14 @@ func findVideoroom(t *testing.T, api AdminAPI, requestFactory *plugins.Videoroom
 }
 
 func TestDefaultAdminAPI_MessagePlugin_Textroom(t *testing.T) {
-	client, err := janus.Connect("ws://localhost:8188/")
+	server := newServer()
+	defer server.Close()
+
+	client, err := janus.Connect(server.URL)
 	noError(t, err)
 	defer client.Close()
 
-	api, err := NewAdminAPI("http://localhost:7088/admin", "janus-go")
+	api, err := NewAdminAPI(server.AdminURL, "janus-go")
 	noError(t, err)
 
 	_, err = api.AddToken("test-token", []string{})
@@ -368,11 +384,14 @@ func findTextroom(t *testing.T, api AdminAPI, requestFactory *plugins.TextroomRe
 }
 
 func TestDefaultAdminAPI_ListHandles(t *testing.T) {
-	client, err := janus.Connect("ws://localhost:8188/")
+	server := newServer()
+	defer server.Close()
+
+	client, err := janus.Connect(server.URL)
 	noError(t, err)
 	defer client.Close()
 
-	api, err := NewAdminAPI("http://localhost:7088/admin", "janus-go")
+	api, err := NewAdminAPI(server.AdminURL, "janus-go")
 	noError(t, err)
 
 	_, err = api.AddToken("test-token", []string{})
@@ -411,11 +430,14 @@ func TestDefaultAdminAPI_ListHandles(t *testing.T) {
 }
 
 func TestDefaultAdminAPI_HandleInfo(t *testing.T) {
-	client, err := janus.Connect("ws://localhost:8188/")
+	server := newServer()
+	defer server.Close()
+
+	client, err := janus.Connect(server.URL)
 	noError(t, err)
 	defer client.Close()
 
-	api, err := NewAdminAPI("http://localhost:7088/admin", "janus-go")
+	api, err := NewAdminAPI(server.AdminURL, "janus-go")
 	noError(t, err)
 
 	_, err = api.AddToken("test-token", []string{})
@@ -448,6 +470,195 @@ func TestDefaultAdminAPI_HandleInfo(t *testing.T) {
 	}
 }
 
+func TestDefaultAdminAPI_KillActions(t *testing.T) {
+	server := newServer()
+	defer server.Close()
+
+	client, err := janus.Connect(server.URL)
+	noError(t, err)
+	defer client.Close()
+	session, err := client.Create()
+	noError(t, err)
+	handle, err := session.Attach(janustest.VideoroomPluginName)
+	noError(t, err)
+
+	api, err := NewAdminAPI(server.AdminURL, "janus-go")
+	noError(t, err)
+
+	hungup, err := api.HangupWebRTC(session.ID, handle.ID)
+	noError(t, err)
+	if hungup.SessionID != session.ID || hungup.HandleID != handle.ID {
+		t.Errorf("unexpected response %+v", hungup)
+	}
+	detached, err := api.DetachHandle(session.ID, handle.ID)
+	noError(t, err)
+	if detached.HandleID != handle.ID {
+		t.Errorf("unexpected response %+v", detached)
+	}
+	for _, expected := range []string{"*janus.HangupMsg", "*janus.DetachedMsg"} {
+		select {
+		case event := <-handle.Events:
+			if got := fmt.Sprintf("%T", event); got != expected {
+				t.Errorf("expected %s, got %s", expected, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no %s delivered", expected)
+		}
+	}
+
+	var gone *GoneError
+	_, err = api.DetachHandle(session.ID, handle.ID)
+	if !errors.As(err, &gone) || gone.HandleID != handle.ID || !errors.Is(err, janus.ErrHandleNotFound) {
+		t.Errorf("expected GoneError for handle %d, got %v", handle.ID, err)
+	}
+
+	destroyed, err := api.DestroySession(session.ID)
+	noError(t, err)
+	if destroyed.SessionID != session.ID {
+		t.Errorf("unexpected response %+v", destroyed)
+	}
+	if len(server.Sessions()) != 0 {
+		t.Errorf("expected no sessions left, got %v", server.Sessions())
+	}
+	_, err = api.HangupWebRTC(session.ID, handle.ID)
+	if !errors.As(err, &gone) || gone.HandleID != 0 || !errors.Is(err, janus.ErrSessionNotFound) {
+		t.Errorf("expected GoneError for session %d, got %v", session.ID, err)
+	}
+}
+
+func TestDefaultAdminAPI_Status(t *testing.T) {
+	server := newServer()
+	defer server.Close()
+
+	api, err := NewAdminAPI(server.AdminURL, "janus-go")
+	noError(t, err)
+
+	info, err := api.Info()
+	noError(t, err)
+	if info.Name == "" || info.Type != "server_info" {
+		t.Errorf("unexpected info %+v", info)
+	}
+	pong, err := api.Ping()
+	noError(t, err)
+	if pong.Type != "pong" {
+		t.Errorf("expecting pong, got %s", pong.Type)
+	}
+
+	timeout, err := api.SetSessionTimeout(120)
+	noError(t, err)
+	if timeout.Timeout != 120 {
+		t.Errorf("expecting timeout 120, got %d", timeout.Timeout)
+	}
+	level, err := api.SetLogLevel(7)
+	noError(t, err)
+	if level.Level != 7 {
+		t.Errorf("expecting level 7, got %d", level.Level)
+	}
+	timestamps, err := api.SetLogTimestamps(true)
+	noError(t, err)
+	colors, err := api.SetLogColors(false)
+	noError(t, err)
+	locking, err := api.SetLockingDebug(true)
+	noError(t, err)
+	refcount, err := api.SetRefcountDebug(true)
+	noError(t, err)
+	libnice, err := api.SetLibniceDebug(true)
+	noError(t, err)
+	if !timestamps.Timestamps || colors.Colors || !locking.LockingDebug || !refcount.RefcountDebug || !libnice.LibniceDebug {
+		t.Error("unexpected boolean settings in responses")
+	}
+	nack, err := api.SetMinNackQueue(500)
+	noError(t, err)
+	noMedia, err := api.SetNoMediaTimer(3)
+	noError(t, err)
+	slowlink, err := api.SetSlowlinkThreshold(10)
+	noError(t, err)
+	if nack.MinNackQueue != 500 || noMedia.NoMediaTimer != 3 || slowlink.SlowlinkThreshold != 10 {
+		t.Error("unexpected numeric settings in responses")
+	}
+
+	status, err := api.GetStatus()
+	noError(t, err)
+	expected := ServerStatus{
+		AdminSecret:       true,
+		SessionTimeout:    120,
+		CandidatesTimeout: 45,
+		LogLevel:          7,
+		LogTimestamps:     true,
+		LockingDebug:      true,
+		RefcountDebug:     true,
+		LibniceDebug:      true,
+		MinNackQueue:      500,
+		NoMediaTimer:      3,
+		SlowlinkThreshold: 10,
+	}
+	if status.Status != expected {
+		t.Errorf("expecting status %+v, got %+v", expected, status.Status)
+	}
+}
+
+func TestDefaultAdminAPI_RecordReplay(t *testing.T) {
+	server := newServer()
+	defer server.Close()
+
+	client, err := janus.Connect(server.URL)
+	noError(t, err)
+	defer client.Close()
+	session, err := client.Create()
+	noError(t, err)
+
+	var buf bytes.Buffer
+	api, err := NewAdminAPI(server.AdminURL, "janus-go", WithRecorder(janus.NewRecorder(&buf)))
+	noError(t, err)
+	_, err = api.ListSessions()
+	noError(t, err)
+	_, err = api.ListHandles(42)
+	if err == nil {
+		t.Fatal("expected an error for a missing session")
+	}
+
+	records, err := janus.ReadRecords(&buf)
+	noError(t, err)
+	server.Close()
+
+	api = NewAdminAPITransport(NewReplayTransport(records), "janus-go")
+	resp, err := api.ListSessions()
+	noError(t, err)
+	if sessions := resp.(*ListSessionsResponse).Sessions; len(sessions) != 1 || sessions[0] != session.ID {
+		t.Errorf("expected session %d, got %v", session.ID, sessions)
+	}
+	if _, err := api.ListHandles(42); !errors.As(err, new(*ErrorAMResponse)) {
+		t.Errorf("expected ErrorAMResponse, got %v", err)
+	}
+
+	var mismatch *janus.ReplayMismatchError
+	if _, err := api.ListTokens(); !errors.As(err, &mismatch) {
+		t.Errorf("expected ReplayMismatchError, got %v", err)
+	}
+}
+
+func TestDefaultAdminAPI_Secret(t *testing.T) {
+	server := newServer()
+	defer server.Close()
+
+	api, err := NewAdminAPI(server.AdminURL, "wrong-secret")
+	noError(t, err)
+
+	// info is answered without the secret, message_plugin is not
+	_, err = api.Info()
+	noError(t, err)
+	_, err = api.MessagePlugin(plugins.NewVideoroomRequestFactory("supersecret").ListRequest())
+	var errResp *ErrorAMResponse
+	if !errors.As(err, &errResp) || errResp.Err.Code != 403 {
+		t.Errorf("expected unauthorized error, got %v", err)
+	}
+}
+
+// newServer starts an emulated Janus with the admin secret of the tests.
+func newServer() *janustest.Server {
+	return janustest.NewServer(janustest.WithAdminSecret("janus-go"))
+}
+
 func noError(t *testing.T, err error) {
 	if err != nil {
 		t.Error(err)
@@ -0,0 +1,325 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Formats of a Capture.
+const (
+	CapturePcap      = "pcap"
+	CaptureText2Pcap = "text2pcap"
+)
+
+// defaultCapturePollInterval is the default CaptureManagerOptions.PollInterval.
+const defaultCapturePollInterval = time.Second
+
+// ErrCaptureRunning is returned by CaptureManager.Start when a capture of the
+// handle is running already. Janus captures a handle to one file at a time.
+var ErrCaptureRunning = errors.New("admin: capture already running")
+
+// ErrNoCapture is returned by CaptureManager.Stop when no capture of the
+// handle is running.
+var ErrNoCapture = errors.New("admin: no capture running")
+
+// CaptureOptions configures a capture started by CaptureManager.Start.
+type CaptureOptions struct {
+	// Format is CapturePcap or CaptureText2Pcap. Defaults to CapturePcap.
+	Format string
+
+	// Folder and Filename locate the file on the server. A name is made up
+	// from the time and the IDs of the session and handle if Filename is
+	// empty.
+	Folder   string
+	Filename string
+
+	// Truncate, if not 0, is the number of bytes packets are truncated to.
+	Truncate int
+
+	// MaxDuration, if not 0, stops the capture once it has run this long.
+	MaxDuration time.Duration
+
+	// MaxSize, if not 0, stops the capture once its file is this large, as
+	// reported by CaptureManagerOptions.Stat.
+	MaxSize int64
+}
+
+// Capture is a packet capture tracked by a CaptureManager.
+type Capture struct {
+	SessionID uint64
+	HandleID  uint64
+	CaptureOptions
+
+	// Started is when the capture was started, Size the size of its file at
+	// the last poll.
+	Started time.Time
+	Size    int64
+}
+
+// Path returns the path of the file of the capture on the server.
+func (c *Capture) Path() string {
+	return path.Join(c.Folder, c.Filename)
+}
+
+// CaptureManagerOptions configures a CaptureManager.
+type CaptureManagerOptions struct {
+	// PollInterval is the time between two checks of the limits of the
+	// running captures. Defaults to one second.
+	PollInterval time.Duration
+
+	// Stat returns the size of the file at path, which is needed for
+	// CaptureOptions.MaxSize. Defaults to os.Stat, which only works when
+	// the server writes to a file system the manager can read.
+	Stat func(path string) (int64, error)
+
+	// OnStop, if set, is called when a capture is stopped because of its
+	// limits, with the error stopping it if any. Captures that fail to stop
+	// are retried at the next poll.
+	OnStop func(capture Capture, err error)
+}
+
+// CaptureManager starts packet captures of handles and stops them once they
+// reach their duration or size limit, so that captures are not left running
+// on a server. A CaptureManager must be closed with Close, which stops the
+// running captures.
+type CaptureManager struct {
+	api  AdminAPI
+	opts CaptureManagerOptions
+
+	mu       sync.Mutex
+	captures map[[2]uint64]*Capture
+	// busy marks the handles with a start or stop request in flight, sent
+	// without holding mu
+	busy map[[2]uint64]bool
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewCaptureManager returns a CaptureManager starting captures with api.
+func NewCaptureManager(api AdminAPI, opts CaptureManagerOptions) *CaptureManager {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = defaultCapturePollInterval
+	}
+	if opts.Stat == nil {
+		opts.Stat = statSize
+	}
+
+	m := &CaptureManager{
+		api:      api,
+		opts:     opts,
+		captures: make(map[[2]uint64]*Capture),
+		busy:     make(map[[2]uint64]bool),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go m.poll()
+	return m
+}
+
+// Start starts a capture of a handle. It fails with ErrCaptureRunning while
+// another capture of the handle is running or being started.
+func (m *CaptureManager) Start(sessionID, handleID uint64, opts CaptureOptions) (Capture, error) {
+	if opts.Format == "" {
+		opts.Format = CapturePcap
+	}
+	if opts.Filename == "" {
+		ext := "pcap"
+		if opts.Format == CaptureText2Pcap {
+			ext = "txt"
+		}
+		opts.Filename = fmt.Sprintf("%s-%d-%d.%s", time.Now().Format("20060102-150405"), sessionID, handleID, ext)
+	}
+
+	key := [2]uint64{sessionID, handleID}
+	m.mu.Lock()
+	if _, ok := m.captures[key]; ok || m.busy[key] {
+		m.mu.Unlock()
+		return Capture{}, ErrCaptureRunning
+	}
+	m.busy[key] = true
+	m.mu.Unlock()
+
+	var err error
+	switch opts.Format {
+	case CapturePcap:
+		_, err = m.api.StartPcap(sessionID, handleID, opts.Folder, opts.Filename, opts.Truncate)
+	case CaptureText2Pcap:
+		_, err = m.api.StartText2Pcap(sessionID, handleID, opts.Folder, opts.Filename, opts.Truncate)
+	default:
+		err = fmt.Errorf("admin: unknown capture format '%s'", opts.Format)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.busy, key)
+	if err != nil {
+		return Capture{}, err
+	}
+	capture := &Capture{SessionID: sessionID, HandleID: handleID, CaptureOptions: opts, Started: time.Now()}
+	m.captures[key] = capture
+	return *capture, nil
+}
+
+// Stop stops the capture of a handle. A capture whose session or handle is
+// gone is stopped already, and is forgotten even though Stop fails with a
+// *GoneError. Stop fails with ErrNoCapture if the capture is being stopped
+// already.
+func (m *CaptureManager) Stop(sessionID, handleID uint64) error {
+	key := [2]uint64{sessionID, handleID}
+	m.mu.Lock()
+	capture, ok := m.captures[key]
+	if !ok || m.busy[key] {
+		m.mu.Unlock()
+		return ErrNoCapture
+	}
+	m.busy[key] = true
+	stop := *capture
+	m.mu.Unlock()
+
+	return m.stopCapture(stop)
+}
+
+// List returns the running captures, ordered by start time.
+func (m *CaptureManager) List() []Capture {
+	m.mu.Lock()
+	list := make([]Capture, 0, len(m.captures))
+	for _, capture := range m.captures {
+		list = append(list, *capture)
+	}
+	m.mu.Unlock()
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Started.Before(list[j].Started) })
+	return list
+}
+
+// StopAll stops all running captures, but those being stopped already, and
+// returns the first error, if any.
+func (m *CaptureManager) StopAll() error {
+	var stops []Capture
+	m.mu.Lock()
+	for key, capture := range m.captures {
+		if !m.busy[key] {
+			m.busy[key] = true
+			stops = append(stops, *capture)
+		}
+	}
+	m.mu.Unlock()
+
+	var first error
+	for _, capture := range stops {
+		var gone *GoneError
+		if err := m.stopCapture(capture); err != nil && !errors.As(err, &gone) && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// Close stops all running captures and the checks of their limits.
+func (m *CaptureManager) Close() error {
+	m.closeOnce.Do(func() {
+		close(m.stop)
+	})
+	<-m.done
+	return m.StopAll()
+}
+
+// stopCapture stops capture and forgets it unless stopping it failed. It is
+// called without mu held, once the handle of capture is marked busy.
+func (m *CaptureManager) stopCapture(capture Capture) error {
+	var err error
+	if capture.Format == CaptureText2Pcap {
+		_, err = m.api.StopText2Pcap(capture.SessionID, capture.HandleID)
+	} else {
+		_, err = m.api.StopPcap(capture.SessionID, capture.HandleID)
+	}
+
+	key := [2]uint64{capture.SessionID, capture.HandleID}
+	var gone *GoneError
+	m.mu.Lock()
+	delete(m.busy, key)
+	if err == nil || errors.As(err, &gone) {
+		delete(m.captures, key)
+	}
+	m.mu.Unlock()
+	return err
+}
+
+// poll stops the captures which reached their limits until Close.
+func (m *CaptureManager) poll() {
+	defer close(m.done)
+	ticker := time.NewTicker(m.opts.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.check()
+		case <-m.stop:
+			return
+		}
+	}
+}
+
+// check stops the captures which reached their limits. The sizes are
+// checked and the captures stopped without holding mu, on a snapshot of the
+// running captures.
+func (m *CaptureManager) check() {
+	m.mu.Lock()
+	captures := make([]Capture, 0, len(m.captures))
+	for key, capture := range m.captures {
+		if !m.busy[key] {
+			captures = append(captures, *capture)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, capture := range captures {
+		size, sized := capture.Size, false
+		if capture.MaxSize > 0 {
+			if s, err := m.opts.Stat(capture.Path()); err == nil {
+				size, sized = s, true
+			}
+		}
+		expired := capture.MaxDuration > 0 && time.Since(capture.Started) >= capture.MaxDuration
+		full := capture.MaxSize > 0 && size >= capture.MaxSize
+
+		// The capture may have been stopped meanwhile
+		key := [2]uint64{capture.SessionID, capture.HandleID}
+		m.mu.Lock()
+		current, ok := m.captures[key]
+		if !ok || m.busy[key] || current.Started != capture.Started {
+			m.mu.Unlock()
+			continue
+		}
+		if sized {
+			current.Size = size
+		}
+		capture = *current
+		if expired || full {
+			m.busy[key] = true
+		}
+		m.mu.Unlock()
+
+		if expired || full {
+			err := m.stopCapture(capture)
+			if m.opts.OnStop != nil {
+				m.opts.OnStop(capture, err)
+			}
+		}
+	}
+}
+
+func statSize(path string) (int64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
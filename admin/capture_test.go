@@ -0,0 +1,154 @@
+package admin
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/timsolov/janus-go"
+	"github.com/timsolov/janus-go/janustest"
+)
+
+func TestCaptureManager(t *testing.T) {
+	server := newServer()
+	defer server.Close()
+
+	client, err := janus.Connect(server.URL)
+	noError(t, err)
+	defer client.Close()
+	session, err := client.Create()
+	noError(t, err)
+	handles := make([]*janus.Handle, 3)
+	for i := range handles {
+		handles[i], err = session.Attach(janustest.VideoroomPluginName)
+		noError(t, err)
+	}
+
+	api, err := NewAdminAPI(server.AdminURL, "janus-go")
+	noError(t, err)
+
+	stopped := make(chan Capture, 2)
+	manager := NewCaptureManager(api, CaptureManagerOptions{
+		PollInterval: 10 * time.Millisecond,
+		Stat: func(path string) (int64, error) {
+			if path == "/tmp/big.txt" {
+				return 2048, nil
+			}
+			return 0, nil
+		},
+		OnStop: func(capture Capture, err error) {
+			if err != nil {
+				t.Error(err)
+			}
+			stopped <- capture
+		},
+	})
+	defer manager.Close()
+
+	// Stopped by the manager
+	_, err = manager.Start(session.ID, handles[0].ID, CaptureOptions{Folder: "/tmp", MaxDuration: 50 * time.Millisecond})
+	noError(t, err)
+	_, err = manager.Start(session.ID, handles[1].ID, CaptureOptions{
+		Format:   CaptureText2Pcap,
+		Folder:   "/tmp",
+		Filename: "big.txt",
+		MaxSize:  1024,
+	})
+	noError(t, err)
+	// Stopped by hand
+	capture, err := manager.Start(session.ID, handles[2].ID, CaptureOptions{Folder: "/tmp", Filename: "manual.pcap"})
+	noError(t, err)
+	if capture.Path() != "/tmp/manual.pcap" {
+		t.Errorf("unexpected path %s", capture.Path())
+	}
+
+	if _, err := manager.Start(session.ID, handles[2].ID, CaptureOptions{}); !errors.Is(err, ErrCaptureRunning) {
+		t.Errorf("expected ErrCaptureRunning, got %v", err)
+	}
+	resp, err := api.HandleInfo(session.ID, handles[2].ID)
+	noError(t, err)
+	if info := resp.(*HandleInfoResponse).Info; info["dump-to-pcap"] != true || info["text2pcap-file"] != "/tmp/manual.pcap" {
+		t.Errorf("capture not running on the server: %v", info)
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case capture := <-stopped:
+			if capture.HandleID == handles[1].ID && capture.Size != 2048 {
+				t.Errorf("expected size 2048, got %d", capture.Size)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("capture not stopped")
+		}
+	}
+	if list := manager.List(); len(list) != 1 || list[0].HandleID != handles[2].ID {
+		t.Errorf("expected only the capture of handle %d, got %+v", handles[2].ID, list)
+	}
+
+	noError(t, manager.Stop(session.ID, handles[2].ID))
+	if err := manager.Stop(session.ID, handles[2].ID); !errors.Is(err, ErrNoCapture) {
+		t.Errorf("expected ErrNoCapture, got %v", err)
+	}
+	resp, err = api.HandleInfo(session.ID, handles[2].ID)
+	noError(t, err)
+	if info := resp.(*HandleInfoResponse).Info; info["dump-to-pcap"] != nil {
+		t.Errorf("capture still running on the server: %v", info)
+	}
+	if len(manager.List()) != 0 {
+		t.Errorf("expected no captures, got %+v", manager.List())
+	}
+}
+
+func TestCaptureManager_SlowServer(t *testing.T) {
+	server := newServer()
+	defer server.Close()
+
+	client, err := janus.Connect(server.URL)
+	noError(t, err)
+	defer client.Close()
+	session, err := client.Create()
+	noError(t, err)
+	handle, err := session.Attach(janustest.VideoroomPluginName)
+	noError(t, err)
+
+	requested := make(chan struct{})
+	release := make(chan struct{})
+	server.HandleAdmin("start_pcap", func(janustest.Request) janustest.Message {
+		close(requested)
+		<-release
+		return nil
+	})
+
+	api, err := NewAdminAPI(server.AdminURL, "janus-go")
+	noError(t, err)
+	manager := NewCaptureManager(api, CaptureManagerOptions{})
+	defer manager.Close()
+
+	started := make(chan error, 1)
+	go func() {
+		_, err := manager.Start(session.ID, handle.ID, CaptureOptions{Folder: "/tmp"})
+		started <- err
+	}()
+	<-requested
+
+	// The manager is not locked while the server answers
+	listed := make(chan []Capture, 1)
+	go func() { listed <- manager.List() }()
+	select {
+	case list := <-listed:
+		if len(list) != 0 {
+			t.Errorf("expected no captures while starting, got %+v", list)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("List blocked by Start")
+	}
+	if _, err := manager.Start(session.ID, handle.ID, CaptureOptions{}); !errors.Is(err, ErrCaptureRunning) {
+		t.Errorf("expected ErrCaptureRunning, got %v", err)
+	}
+
+	close(release)
+	noError(t, <-started)
+	if list := manager.List(); len(list) != 1 || list[0].HandleID != handle.ID {
+		t.Errorf("expected the capture of handle %d, got %+v", handle.ID, list)
+	}
+}
@@ -0,0 +1,136 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/timsolov/janus-go"
+)
+
+// defaultDrainPollInterval is the default DrainOptions.PollInterval.
+const defaultDrainPollInterval = time.Second
+
+// DrainOptions configures Drain.
+type DrainOptions struct {
+	// PollInterval is the time between two counts of the sessions and
+	// handles left. Defaults to one second.
+	PollInterval time.Duration
+
+	// ForceDestroy makes Drain destroy the sessions left once ctx is done,
+	// instead of failing with the error of ctx.
+	ForceDestroy bool
+
+	// Progress, if set, is called after every count and after the sessions
+	// left have been destroyed.
+	Progress func(progress DrainProgress)
+}
+
+// DrainProgress reports the state of a Drain.
+type DrainProgress struct {
+	// Sessions and Handles are the numbers of sessions and handles left at
+	// the last count.
+	Sessions int
+	Handles  int
+
+	// Destroyed is the number of sessions destroyed by ForceDestroy.
+	Destroyed int
+}
+
+// Drain prepares the server for a shutdown: it stops accepting new
+// sessions, then waits until the existing sessions are gone or ctx is done.
+// If sessions are left when ctx is done, Drain fails with the error of ctx
+// unless opts.ForceDestroy is set, in which case they are destroyed and the
+// first error destroying them is returned, if any. The server keeps refusing
+// new sessions until Undrain.
+func (api *DefaultAdminAPI) Drain(ctx context.Context, opts DrainOptions) (DrainProgress, error) {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = defaultDrainPollInterval
+	}
+	report := func(progress DrainProgress) {
+		if opts.Progress != nil {
+			opts.Progress(progress)
+		}
+	}
+
+	if _, err := api.AcceptNewSessions(false); err != nil {
+		return DrainProgress{}, err
+	}
+
+	ticker := time.NewTicker(opts.PollInterval)
+	defer ticker.Stop()
+	for {
+		progress, sessions, err := api.count()
+		if err != nil {
+			return progress, err
+		}
+		report(progress)
+		if progress.Sessions == 0 {
+			return progress, nil
+		}
+
+		select {
+		case <-ticker.C:
+			continue
+		case <-ctx.Done():
+		}
+
+		if !opts.ForceDestroy {
+			return progress, ctx.Err()
+		}
+		api.logger.Warn("destroying sessions left after drain", "sessions", progress.Sessions, "handles", progress.Handles)
+
+		var first error
+		for _, id := range sessions {
+			_, err := api.DestroySession(id)
+			var goneErr *GoneError
+			switch {
+			case err == nil:
+				progress.Destroyed++
+			case errors.As(err, &goneErr):
+				// Gone meanwhile
+			case first == nil:
+				first = err
+			}
+		}
+		report(progress)
+		return progress, first
+	}
+}
+
+// Undrain makes the server accept new sessions again after Drain.
+func (api *DefaultAdminAPI) Undrain() error {
+	_, err := api.AcceptNewSessions(true)
+	return err
+}
+
+// count returns the numbers of sessions and handles of the server, and the
+// IDs of the sessions.
+func (api *DefaultAdminAPI) count() (DrainProgress, []uint64, error) {
+	var progress DrainProgress
+	resp, err := api.ListSessions()
+	if err != nil {
+		return progress, nil, err
+	}
+	list, ok := resp.(*ListSessionsResponse)
+	if !ok {
+		return progress, nil, unexpected("list_sessions", resp)
+	}
+
+	progress.Sessions = len(list.Sessions)
+	for _, id := range list.Sessions {
+		resp, err := api.ListHandles(id)
+		if errors.Is(err, janus.ErrSessionNotFound) {
+			// Gone since the session list was returned
+			progress.Sessions--
+			continue
+		}
+		if err != nil {
+			return progress, nil, err
+		}
+		if handles, ok := resp.(*ListHandlesResponse); ok {
+			progress.Handles += len(handles.Handles)
+		}
+	}
+	return progress, list.Sessions, nil
+}
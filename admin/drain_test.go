@@ -0,0 +1,74 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/timsolov/janus-go"
+	"github.com/timsolov/janus-go/janustest"
+)
+
+func TestDefaultAdminAPI_Drain(t *testing.T) {
+	server := newServer()
+	defer server.Close()
+
+	client, err := janus.Connect(server.URL)
+	noError(t, err)
+	defer client.Close()
+
+	leaving, err := client.Create()
+	noError(t, err)
+	_, err = leaving.Attach(janustest.VideoroomPluginName)
+	noError(t, err)
+	_, err = client.Create()
+	noError(t, err)
+
+	api, err := NewAdminAPI(server.AdminURL, "janus-go")
+	noError(t, err)
+
+	var reports []DrainProgress
+	opts := DrainOptions{
+		PollInterval: 10 * time.Millisecond,
+		Progress: func(progress DrainProgress) {
+			if len(reports) == 0 {
+				go leaving.Destroy()
+			}
+			reports = append(reports, progress)
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	progress, err := api.Drain(ctx, opts)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if progress.Sessions != 1 || progress.Handles != 0 {
+		t.Errorf("expected 1 session and no handle left, got %+v", progress)
+	}
+	if first := reports[0]; first.Sessions != 2 || first.Handles != 1 {
+		t.Errorf("expected 2 sessions and 1 handle at first, got %+v", first)
+	}
+
+	if _, err := client.Create(); !errors.Is(err, janus.ErrNotAcceptingSessions) {
+		t.Errorf("expected ErrNotAcceptingSessions, got %v", err)
+	}
+
+	opts.ForceDestroy = true
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	progress, err = api.Drain(ctx, opts)
+	noError(t, err)
+	if progress.Destroyed != 1 {
+		t.Errorf("expected 1 session destroyed, got %+v", progress)
+	}
+	if len(server.Sessions()) != 0 {
+		t.Errorf("expected no sessions left, got %v", server.Sessions())
+	}
+
+	noError(t, api.Undrain())
+	_, err = client.Create()
+	noError(t, err)
+}
@@ -0,0 +1,100 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/timsolov/janus-go"
+)
+
+// WithRecorder captures every frame sent and received by the HTTP, WebSocket
+// or Unix Sockets transport of the admin API client with rec. The
+// admin_secret, token and apisecret fields of the frames, including the
+// tokens listed by list_tokens, are recorded as janus.Redacted. Recorded traffic is
+// served back by a ReplayTransport.
+func WithRecorder(rec *janus.Recorder) Option {
+	return func(api *DefaultAdminAPI) {
+		api.recorder = rec
+	}
+}
+
+// record writes a frame or failure with rec, if any. Recording errors are
+// ignored.
+func record(rec *janus.Recorder, direction string, frame []byte, err error) {
+	if rec == nil {
+		return
+	}
+	if err != nil {
+		rec.RecordErr(err)
+		return
+	}
+	rec.Record(direction, frame)
+}
+
+// ReplayTransport is a Transport answering admin requests with recorded
+// responses, typically read with janus.ReadRecords. Every request must be
+// the one recorded next, otherwise it fails with a janus.ReplayMismatchError.
+type ReplayTransport struct {
+	mu      sync.Mutex
+	records []janus.Record
+	next    int
+}
+
+// NewReplayTransport returns a ReplayTransport serving records.
+func NewReplayTransport(records []janus.Record) *ReplayTransport {
+	return &ReplayTransport{records: records}
+}
+
+// Request answers r with the response recorded to the next request, or with
+// the failure recorded in its place.
+func (t *ReplayTransport) Request(r APIRequest) (interface{}, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	sent, err := t.nextSend(r.ActionName())
+	if err != nil {
+		return nil, err
+	}
+
+	for ; t.next < len(t.records); t.next++ {
+		record := t.records[t.next]
+		switch {
+		case record.Direction == janus.RecordError:
+			t.next++
+			return nil, fmt.Errorf("replay: %s", record.Error)
+		case record.Direction == janus.RecordReceive && record.Transaction == sent.Transaction:
+			t.next++
+			return parseResponse(r, record.Frame)
+		case record.Direction == janus.RecordSend:
+			return nil, fmt.Errorf("replay: no response recorded to '%s' request", r.ActionName())
+		}
+	}
+	return nil, fmt.Errorf("replay: no response recorded to '%s' request", r.ActionName())
+}
+
+// nextSend skips to the request recorded next and checks it has the type
+// action. It is called with mu held.
+func (t *ReplayTransport) nextSend(action string) (janus.Record, error) {
+	for ; t.next < len(t.records); t.next++ {
+		record := t.records[t.next]
+		if record.Direction != janus.RecordSend {
+			continue
+		}
+
+		var req struct {
+			Type string `json:"janus"`
+		}
+		json.Unmarshal(record.Frame, &req)
+		if req.Type != action {
+			return record, &janus.ReplayMismatchError{Expected: req.Type, Got: action}
+		}
+		t.next++
+		return record, nil
+	}
+	return janus.Record{}, &janus.ReplayMismatchError{Got: action}
+}
+
+func (t *ReplayTransport) Close() error {
+	return nil
+}
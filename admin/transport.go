@@ -7,7 +7,11 @@ import (
 	"io/ioutil"
 	"net"
 	"net/http"
+	"sync"
 	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/timsolov/janus-go"
 )
 
 type TransportError struct {
@@ -27,6 +31,7 @@ type Transport interface {
 type HttpTransport struct {
 	client *http.Client
 	url    string
+	rec    *janus.Recorder
 }
 
 func NewHttpTransport(url string) *HttpTransport {
@@ -51,26 +56,121 @@ func (t *HttpTransport) Request(r APIRequest) (interface{}, error) {
 		return nil, err
 	}
 
-	req, err := http.NewRequest(http.MethodPost, t.url+r.Endpoint(), bytes.NewBuffer(b))
+	body, err := t.post(r.Endpoint(), b)
+	if err != nil {
+		record(t.rec, janus.RecordError, nil, err)
+		return nil, err
+	}
+	record(t.rec, janus.RecordReceive, body, nil)
+
+	return parseResponse(r, body)
+}
+
+func (t *HttpTransport) post(endpoint string, b []byte) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodPost, t.url+endpoint, bytes.NewBuffer(b))
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/json; charset=utf-8")
 
+	record(t.rec, janus.RecordSend, b, nil)
 	resp, err := t.client.Do(req)
 	if err != nil {
 		return nil, err
 	}
+	defer resp.Body.Close()
 	if resp.StatusCode >= http.StatusBadRequest {
 		return nil, &TransportError{Code: resp.StatusCode, Msg: resp.Status}
 	}
+	return ioutil.ReadAll(resp.Body)
+}
 
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
+func (t *HttpTransport) Close() error {
+	return nil
+}
+
+// UnixTransport sends admin requests to the Janus Unix Sockets transport.
+// The socket is dialed on the first request and redialed after a failure.
+// Requests are sent one at a time.
+type UnixTransport struct {
+	path    string
+	timeout time.Duration
+	rec     *janus.Recorder
+
+	mu   sync.Mutex
+	conn net.Conn
+	buf  []byte
+}
+
+func NewUnixTransport(path string) *UnixTransport {
+	t := new(UnixTransport)
+	t.path = path
+	t.timeout = 10 * time.Second
+	t.buf = make([]byte, janus.MaxUnixFrameSize)
+	return t
+}
+
+func (t *UnixTransport) Request(r APIRequest) (interface{}, error) {
+	payload := r.Payload()
+	b, err := json.Marshal(payload)
 	if err != nil {
 		return nil, err
 	}
 
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.conn == nil {
+		if t.conn, err = janus.DialUnix(t.path); err != nil {
+			return nil, err
+		}
+	}
+
+	body, err := t.roundTrip(b, payload["transaction"])
+	if err != nil {
+		record(t.rec, janus.RecordError, nil, err)
+		t.conn.Close()
+		t.conn = nil
+		return nil, err
+	}
+
+	return parseResponse(r, body)
+}
+
+// roundTrip writes a request and reads packets until the response carrying
+// the same transaction arrives, skipping stale responses to requests that
+// failed earlier.
+func (t *UnixTransport) roundTrip(b []byte, transaction interface{}) ([]byte, error) {
+	if err := t.conn.SetDeadline(time.Now().Add(t.timeout)); err != nil {
+		return nil, err
+	}
+	record(t.rec, janus.RecordSend, b, nil)
+	if _, err := t.conn.Write(b); err != nil {
+		return nil, err
+	}
+
+	for {
+		n, err := t.conn.Read(t.buf)
+		if err != nil {
+			return nil, err
+		}
+		record(t.rec, janus.RecordReceive, t.buf[:n], nil)
+
+		var base BaseAMResponse
+		if err := json.Unmarshal(t.buf[:n], &base); err != nil {
+			return nil, fmt.Errorf("json.Unmarshal: %w", err)
+		}
+		if base.ID == transaction {
+			body := make([]byte, n)
+			copy(body, t.buf[:n])
+			return body, nil
+		}
+	}
+}
+
+// parseResponse parses the response to r, returning error responses as
+// errors.
+func parseResponse(r APIRequest, body []byte) (interface{}, error) {
 	pResp, err := ParseAMResponse(r, body)
 	if err != nil {
 		return nil, err
@@ -83,6 +183,176 @@ func (t *HttpTransport) Request(r APIRequest) (interface{}, error) {
 		return pResp, nil
 	}
 }
-func (t *HttpTransport) Close() error {
-	return nil
+
+func (t *UnixTransport) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.conn == nil {
+		return nil
+	}
+	err := t.conn.Close()
+	t.conn = nil
+	return err
+}
+
+// WsTransport sends admin requests to the Janus WebSocket transport, using
+// the janus-admin-protocol subprotocol. Requests share one persistent
+// connection and may be sent concurrently, responses are matched to their
+// request by transaction. The connection is dialed on the first request and
+// redialed by the next request after a failure.
+type WsTransport struct {
+	url     string
+	dialer  *websocket.Dialer
+	timeout time.Duration
+	rec     *janus.Recorder
+
+	mu     sync.Mutex
+	conn   *wsConn
+	closed bool
+}
+
+// wsConn is a connection of a WsTransport and the requests waiting for a
+// response on it.
+type wsConn struct {
+	ws      *websocket.Conn
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	pending map[string]chan []byte
+
+	// done is closed when reading fails, err holds the reason
+	done chan struct{}
+	err  error
+}
+
+func NewWsTransport(url string) *WsTransport {
+	t := new(WsTransport)
+	t.url = url
+	t.timeout = 10 * time.Second
+	t.dialer = &websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: 5 * time.Second,
+		Subprotocols:     []string{"janus-admin-protocol"},
+	}
+	return t
+}
+
+func (t *WsTransport) Request(r APIRequest) (interface{}, error) {
+	payload := r.Payload()
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	transaction, _ := payload["transaction"].(string)
+
+	c, err := t.connect()
+	if err != nil {
+		record(t.rec, janus.RecordError, nil, err)
+		return nil, err
+	}
+
+	ch := make(chan []byte, 1)
+	c.mu.Lock()
+	c.pending[transaction] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, transaction)
+		c.mu.Unlock()
+	}()
+
+	record(t.rec, janus.RecordSend, b, nil)
+	c.writeMu.Lock()
+	c.ws.SetWriteDeadline(time.Now().Add(t.timeout))
+	err = c.ws.WriteMessage(websocket.TextMessage, b)
+	c.writeMu.Unlock()
+	if err != nil {
+		record(t.rec, janus.RecordError, nil, err)
+		t.drop(c)
+		return nil, err
+	}
+
+	timer := time.NewTimer(t.timeout)
+	defer timer.Stop()
+	select {
+	case body := <-ch:
+		return parseResponse(r, body)
+	case <-c.done:
+		return nil, c.err
+	case <-timer.C:
+		return nil, fmt.Errorf("'%s' request: no response within %s", r.ActionName(), t.timeout)
+	}
+}
+
+// connect returns the current connection, dialing a new one if there is
+// none.
+func (t *WsTransport) connect() (*wsConn, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return nil, janus.ErrTransportClosed
+	}
+	if t.conn != nil {
+		return t.conn, nil
+	}
+
+	ws, _, err := t.dialer.Dial(t.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	t.conn = &wsConn{ws: ws, pending: make(map[string]chan []byte), done: make(chan struct{})}
+	go t.read(t.conn)
+	return t.conn, nil
+}
+
+// read passes the responses received on c to their requests until reading
+// fails, which fails the requests still waiting.
+func (t *WsTransport) read(c *wsConn) {
+	for {
+		_, body, err := c.ws.ReadMessage()
+		if err != nil {
+			record(t.rec, janus.RecordError, nil, err)
+			c.err = err
+			close(c.done)
+			t.drop(c)
+			return
+		}
+		record(t.rec, janus.RecordReceive, body, nil)
+
+		var base BaseAMResponse
+		if err := json.Unmarshal(body, &base); err != nil {
+			continue
+		}
+		c.mu.Lock()
+		ch := c.pending[base.ID]
+		c.mu.Unlock()
+		if ch != nil {
+			select {
+			case ch <- body:
+			default:
+			}
+		}
+	}
+}
+
+// drop closes c and forgets it, so the next request redials.
+func (t *WsTransport) drop(c *wsConn) {
+	t.mu.Lock()
+	if t.conn == c {
+		t.conn = nil
+	}
+	t.mu.Unlock()
+	c.ws.Close()
+}
+
+func (t *WsTransport) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closed = true
+	if t.conn == nil {
+		return nil
+	}
+	err := t.conn.ws.Close()
+	t.conn = nil
+	return err
 }
@@ -0,0 +1,142 @@
+package admin
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/timsolov/janus-go"
+)
+
+// socketpair returns both ends of a connected SOCK_SEQPACKET socket pair.
+func socketpair(t *testing.T) (net.Conn, net.Conn) {
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_SEQPACKET, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conns := make([]net.Conn, 2)
+	for i, fd := range fds {
+		f := os.NewFile(uintptr(fd), "socketpair")
+		conns[i], err = net.FileConn(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return conns[0], conns[1]
+}
+
+func TestUnixTransport_Request(t *testing.T) {
+	client, server := socketpair(t)
+	defer server.Close()
+
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, err := server.Read(buf)
+			if err != nil {
+				return
+			}
+			var req map[string]interface{}
+			if err := json.Unmarshal(buf[:n], &req); err != nil {
+				t.Error(err)
+				return
+			}
+
+			// a stale response must be skipped
+			stale, _ := json.Marshal(map[string]interface{}{"janus": "success", "transaction": "stale"})
+			server.Write(stale)
+
+			resp, _ := json.Marshal(map[string]interface{}{
+				"janus":       "success",
+				"transaction": req["transaction"],
+				"sessions":    []uint64{1, 2},
+			})
+			server.Write(resp)
+		}
+	}()
+
+	api, err := NewAdminAPI("unix:///tmp/janus-admin.sock", "janus-go")
+	noError(t, err)
+	transport, ok := api.transport.(*UnixTransport)
+	if !ok {
+		t.Fatalf("expected *UnixTransport, got %T", api.transport)
+	}
+	transport.conn = client
+	defer api.Close()
+
+	resp, err := api.ListSessions()
+	noError(t, err)
+	sessions, ok := resp.(*ListSessionsResponse)
+	if !ok {
+		t.Fatalf("wrong type: ListSessionsResponse != %v", resp)
+	}
+	if len(sessions.Sessions) != 2 {
+		t.Errorf("expecting 2 sessions, found %d", len(sessions.Sessions))
+	}
+}
+
+func TestWsTransport_Request(t *testing.T) {
+	server := newServer()
+	defer server.Close()
+
+	client, err := janus.Connect(server.URL)
+	noError(t, err)
+	defer client.Close()
+	for i := 0; i < 3; i++ {
+		_, err := client.Create()
+		noError(t, err)
+	}
+
+	api, err := NewAdminAPI(server.AdminWsURL, "janus-go")
+	noError(t, err)
+	defer api.Close()
+	transport, ok := api.transport.(*WsTransport)
+	if !ok {
+		t.Fatalf("expected *WsTransport, got %T", api.transport)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if i%2 == 0 {
+				resp, err := api.ListSessions()
+				if err != nil {
+					t.Error(err)
+				} else if n := len(resp.(*ListSessionsResponse).Sessions); n != 3 {
+					t.Errorf("expecting 3 sessions, found %d", n)
+				}
+				return
+			}
+			if _, err := api.ListTokens(); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// The next request redials once the connection is lost
+	server.CloseConnections()
+	deadline := time.Now().Add(time.Second)
+	for {
+		transport.mu.Lock()
+		dropped := transport.conn == nil
+		transport.mu.Unlock()
+		if dropped {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("connection not dropped")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	_, err = api.ListSessions()
+	noError(t, err)
+}
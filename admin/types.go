@@ -91,6 +91,43 @@ func (r *HandleRequest) Payload() map[string]interface{} {
 	return m
 }
 
+// SettingRequest is a request changing a setting of the server, sent with
+// the value under the name of the setting, e.g. "level" for set_log_level.
+type SettingRequest struct {
+	BaseRequest
+	Name  string
+	Value interface{}
+}
+
+func (r *SettingRequest) Payload() map[string]interface{} {
+	m := r.BaseRequest.Payload()
+	m[r.Name] = r.Value
+	return m
+}
+
+// CaptureRequest starts a packet capture of a handle, with start_pcap or
+// start_text2pcap.
+type CaptureRequest struct {
+	HandleRequest
+	Folder   string
+	Filename string
+	Truncate int
+}
+
+func (r *CaptureRequest) Payload() map[string]interface{} {
+	m := r.HandleRequest.Payload()
+	if r.Folder != "" {
+		m["folder"] = r.Folder
+	}
+	if r.Filename != "" {
+		m["filename"] = r.Filename
+	}
+	if r.Truncate > 0 {
+		m["truncate"] = r.Truncate
+	}
+	return m
+}
+
 type BaseAMResponse struct {
 	Type string `json:"janus"`
 	ID   string `json:"transaction"`
@@ -105,6 +142,35 @@ func (err *ErrorAMResponse) Error() string {
 	return err.Err.Reason
 }
 
+// Is reports whether target is the janus sentinel error of the same code,
+// e.g. errors.Is(err, janus.ErrSessionNotFound).
+func (err *ErrorAMResponse) Is(target error) bool {
+	t, ok := target.(*janus.ErrorData)
+	return ok && t.Code == err.Err.Code
+}
+
+// GoneError is returned by the requests acting on a session or handle the
+// server does not know, usually because it is gone already. Err is the
+// *ErrorAMResponse of the server, so errors.Is(err, janus.ErrSessionNotFound)
+// and errors.Is(err, janus.ErrHandleNotFound) work as expected.
+type GoneError struct {
+	SessionID uint64
+	// HandleID is 0 if the session is gone.
+	HandleID uint64
+	Err      error
+}
+
+func (err *GoneError) Error() string {
+	if err.HandleID == 0 {
+		return fmt.Sprintf("session %d: %s", err.SessionID, err.Err)
+	}
+	return fmt.Sprintf("handle %d of session %d: %s", err.HandleID, err.SessionID, err.Err)
+}
+
+func (err *GoneError) Unwrap() error {
+	return err.Err
+}
+
 type SuccessAMResponse struct {
 	BaseAMResponse
 	Data map[string]interface{} `json:"data"`
@@ -145,11 +211,121 @@ type HandleResponse struct {
 	HandleID uint64 `json:"handle_id"`
 }
 
+type DestroySessionResponse struct {
+	SessionResponse
+}
+
+type DetachHandleResponse struct {
+	HandleResponse
+}
+
+type HangupWebRTCResponse struct {
+	HandleResponse
+}
+
+// CaptureResponse answers the start_pcap, start_text2pcap, stop_pcap and
+// stop_text2pcap requests.
+type CaptureResponse struct {
+	HandleResponse
+}
+
 type HandleInfoResponse struct {
 	HandleResponse
 	Info map[string]interface{} `json:"info"`
 }
 
+// InfoResponse answers an info request.
+type InfoResponse struct {
+	BaseAMResponse
+	janus.InfoMsg
+}
+
+// PingResponse answers a ping request.
+type PingResponse struct {
+	BaseAMResponse
+}
+
+// ServerStatus holds the runtime settings of the server, see the set_...
+// requests.
+type ServerStatus struct {
+	TokenAuth             bool `json:"token_auth"`
+	APISecret             bool `json:"api_secret"`
+	AdminSecret           bool `json:"admin_secret"`
+	SessionTimeout        int  `json:"session_timeout"`
+	ReclaimSessionTimeout int  `json:"reclaim_session_timeout"`
+	CandidatesTimeout     int  `json:"candidates_timeout"`
+	LogLevel              int  `json:"log_level"`
+	LogTimestamps         bool `json:"log_timestamps"`
+	LogColors             bool `json:"log_colors"`
+	LockingDebug          bool `json:"locking_debug"`
+	RefcountDebug         bool `json:"refcount_debug"`
+	LibniceDebug          bool `json:"libnice_debug"`
+	MinNackQueue          int  `json:"min_nack_queue"`
+	NackOptimizations     bool `json:"nack-optimizations"`
+	NoMediaTimer          int  `json:"no_media_timer"`
+	SlowlinkThreshold     int  `json:"slowlink_threshold"`
+}
+
+type GetStatusResponse struct {
+	BaseAMResponse
+	Status ServerStatus `json:"status"`
+}
+
+type SessionTimeoutResponse struct {
+	BaseAMResponse
+	Timeout int `json:"timeout"`
+}
+
+type LogLevelResponse struct {
+	BaseAMResponse
+	Level int `json:"level"`
+}
+
+type LogTimestampsResponse struct {
+	BaseAMResponse
+	Timestamps bool `json:"log_timestamps"`
+}
+
+type LogColorsResponse struct {
+	BaseAMResponse
+	Colors bool `json:"log_colors"`
+}
+
+type LockingDebugResponse struct {
+	BaseAMResponse
+	LockingDebug bool `json:"locking_debug"`
+}
+
+type RefcountDebugResponse struct {
+	BaseAMResponse
+	RefcountDebug bool `json:"refcount_debug"`
+}
+
+type LibniceDebugResponse struct {
+	BaseAMResponse
+	LibniceDebug bool `json:"libnice_debug"`
+}
+
+type MinNackQueueResponse struct {
+	BaseAMResponse
+	MinNackQueue int `json:"min_nack_queue"`
+}
+
+type NoMediaTimerResponse struct {
+	BaseAMResponse
+	NoMediaTimer int `json:"no_media_timer"`
+}
+
+type AcceptNewSessionsResponse struct {
+	BaseAMResponse
+	Accept bool `json:"accept"`
+}
+
+type SlowlinkThresholdResponse struct {
+	BaseAMResponse
+	SlowlinkThreshold int `json:"slowlink_threshold"`
+}
+
 var amResponseTypes = map[string]func() interface{}{
 	"success":        func() interface{} { return &SuccessAMResponse{} },
 	"error":          func() interface{} { return &ErrorAMResponse{} },
@@ -158,6 +334,29 @@ var amResponseTypes = map[string]func() interface{}{
 	"message_plugin": func() interface{} { return &MessagePluginResponse{} },
 	"list_handles":   func() interface{} { return &ListHandlesResponse{} },
 	"handle_info":    func() interface{} { return &HandleInfoResponse{} },
+
+	"destroy_session": func() interface{} { return &DestroySessionResponse{} },
+	"detach_handle":   func() interface{} { return &DetachHandleResponse{} },
+	"hangup_webrtc":   func() interface{} { return &HangupWebRTCResponse{} },
+	"start_pcap":      func() interface{} { return &CaptureResponse{} },
+	"start_text2pcap": func() interface{} { return &CaptureResponse{} },
+	"stop_pcap":       func() interface{} { return &CaptureResponse{} },
+	"stop_text2pcap":  func() interface{} { return &CaptureResponse{} },
+
+	"server_info":            func() interface{} { return &InfoResponse{} },
+	"pong":                   func() interface{} { return &PingResponse{} },
+	"get_status":             func() interface{} { return &GetStatusResponse{} },
+	"set_session_timeout":    func() interface{} { return &SessionTimeoutResponse{} },
+	"set_log_level":          func() interface{} { return &LogLevelResponse{} },
+	"set_log_timestamps":     func() interface{} { return &LogTimestampsResponse{} },
+	"set_log_colors":         func() interface{} { return &LogColorsResponse{} },
+	"set_locking_debug":      func() interface{} { return &LockingDebugResponse{} },
+	"set_refcount_debug":     func() interface{} { return &RefcountDebugResponse{} },
+	"set_libnice_debug":      func() interface{} { return &LibniceDebugResponse{} },
+	"set_min_nack_queue":     func() interface{} { return &MinNackQueueResponse{} },
+	"set_no_media_timer":     func() interface{} { return &NoMediaTimerResponse{} },
+	"set_slowlink_threshold": func() interface{} { return &SlowlinkThresholdResponse{} },
+	"accept_new_sessions":    func() interface{} { return &AcceptNewSessionsResponse{} },
 }
 
 func ParseAMResponse(r APIRequest, data []byte) (interface{}, error) {
@@ -185,10 +384,13 @@ func ParseAMResponse(r APIRequest, data []byte) (interface{}, error) {
 		return nil, fmt.Errorf("json.Unmarshal %s : %w", typeStr, err)
 	}
 
-	if mpRequest, ok := r.(*MessagePluginRequest); ok {
+	// Error responses, e.g. to a message_plugin request without the admin
+	// secret, are returned as is
+	mpResponse, isMP := resp.(*MessagePluginResponse)
+	if mpRequest, ok := r.(*MessagePluginRequest); ok && isMP {
 		if pluginTypes, ok := plugins.TypeMap[mpRequest.Request.PluginName()]; ok {
 			actionName := mpRequest.Request.ActionName()
-			innerPayload := resp.(*MessagePluginResponse).Response
+			innerPayload := mpResponse.Response
 			if _, ok := innerPayload["error"]; ok {
 				actionName = "error"
 			}
@@ -0,0 +1,126 @@
+package janus
+
+import (
+	"errors"
+	"fmt"
+)
+
+// TimeoutError is returned by the ...Context request methods when the
+// context is done before the Gateway answered the request. Err holds the
+// context error, so errors.Is(err, context.DeadlineExceeded) and
+// errors.Is(err, context.Canceled) work as expected.
+type TimeoutError struct {
+	// Request is the janus request type that timed out, e.g. "attach".
+	Request string
+	Err     error
+}
+
+func (err *TimeoutError) Error() string {
+	return fmt.Sprintf("'%s' request: %s", err.Request, err.Err)
+}
+
+func (err *TimeoutError) Unwrap() error {
+	return err.Err
+}
+
+// Timeout reports true, so TimeoutError satisfies the Timeout method of
+// net.Error.
+func (err *TimeoutError) Timeout() bool {
+	return true
+}
+
+// ErrConnectionClosed is reported by Gateway.Err, and returned by every
+// pending and subsequent request, once the connection to the Gateway is lost.
+var ErrConnectionClosed = errors.New("janus: connection closed")
+
+// ErrSessionDestroyed is returned by the requests of a Session that is being
+// destroyed or is gone, see Session.State.
+var ErrSessionDestroyed = errors.New("janus: session destroyed")
+
+// ErrHandleDetached is returned by the requests of a Handle that is being
+// detached or is gone, see Handle.State.
+var ErrHandleDetached = errors.New("janus: handle detached")
+
+// ErrMessageClosed is returned by PendingMessage.Next once the message is
+// closed.
+var ErrMessageClosed = errors.New("janus: message closed")
+
+// ErrSendQueueFull is returned by a request when the queue of frames waiting
+// to be written to the connection is full, see WithSendQueue.
+var ErrSendQueueFull = errors.New("janus: send queue full")
+
+// Error codes of the Janus core API, see
+// https://janus.conf.meetecho.com/docs/rest.html#errors
+const (
+	CodeUnauthorized            = 403
+	CodeUnauthorizedPlugin      = 405
+	CodeTransportSpecific       = 450
+	CodeMissingRequest          = 452
+	CodeUnknownRequest          = 453
+	CodeInvalidJSON             = 454
+	CodeInvalidJSONObject       = 455
+	CodeMissingMandatoryElement = 456
+	CodeInvalidRequestPath      = 457
+	CodeSessionNotFound         = 458
+	CodeHandleNotFound          = 459
+	CodePluginNotFound          = 460
+	CodePluginAttach            = 461
+	CodePluginMessage           = 462
+	CodePluginDetach            = 463
+	CodeJSEPUnknownType         = 464
+	CodeJSEPInvalidSDP          = 465
+	CodeTrickleInvalidStream    = 466
+	CodeInvalidElementType      = 467
+	CodeSessionConflict         = 468
+	CodeUnexpectedAnswer        = 469
+	CodeTokenNotFound           = 470
+	CodeWebRTCState             = 471
+	CodeNotAcceptingSessions    = 472
+	CodeUnknown                 = 490
+)
+
+// Sentinel errors for the Janus core error codes. Errors returned by the
+// Gateway (*ErrorMsg) and by the admin API (*admin.ErrorAMResponse) match
+// the sentinel of their code with errors.Is, e.g.
+//
+//	if errors.Is(err, janus.ErrSessionNotFound) {
+//		// create a new session
+//	}
+var (
+	ErrUnauthorized            = &ErrorData{Code: CodeUnauthorized, Reason: "Unauthorized request"}
+	ErrUnauthorizedPlugin      = &ErrorData{Code: CodeUnauthorizedPlugin, Reason: "Unauthorized access to plugin"}
+	ErrTransportSpecific       = &ErrorData{Code: CodeTransportSpecific, Reason: "Transport specific error"}
+	ErrMissingRequest          = &ErrorData{Code: CodeMissingRequest, Reason: "Missing request"}
+	ErrUnknownRequest          = &ErrorData{Code: CodeUnknownRequest, Reason: "Unknown request"}
+	ErrInvalidJSON             = &ErrorData{Code: CodeInvalidJSON, Reason: "Invalid JSON"}
+	ErrInvalidJSONObject       = &ErrorData{Code: CodeInvalidJSONObject, Reason: "Invalid JSON Object"}
+	ErrMissingMandatoryElement = &ErrorData{Code: CodeMissingMandatoryElement, Reason: "Missing mandatory element"}
+	ErrInvalidRequestPath      = &ErrorData{Code: CodeInvalidRequestPath, Reason: "Invalid path for this request"}
+	ErrSessionNotFound         = &ErrorData{Code: CodeSessionNotFound, Reason: "Session not found"}
+	ErrHandleNotFound          = &ErrorData{Code: CodeHandleNotFound, Reason: "Handle not found"}
+	ErrPluginNotFound          = &ErrorData{Code: CodePluginNotFound, Reason: "Plugin not found"}
+	ErrPluginAttach            = &ErrorData{Code: CodePluginAttach, Reason: "Error attaching plugin"}
+	ErrPluginMessage           = &ErrorData{Code: CodePluginMessage, Reason: "Error sending message to plugin"}
+	ErrPluginDetach            = &ErrorData{Code: CodePluginDetach, Reason: "Error detaching from plugin"}
+	ErrJSEPUnknownType         = &ErrorData{Code: CodeJSEPUnknownType, Reason: "Unsupported JSEP type"}
+	ErrJSEPInvalidSDP          = &ErrorData{Code: CodeJSEPInvalidSDP, Reason: "Invalid SDP"}
+	ErrTrickleInvalidStream    = &ErrorData{Code: CodeTrickleInvalidStream, Reason: "Invalid stream"}
+	ErrInvalidElementType      = &ErrorData{Code: CodeInvalidElementType, Reason: "Invalid element type"}
+	ErrSessionConflict         = &ErrorData{Code: CodeSessionConflict, Reason: "Session ID already in use"}
+	ErrUnexpectedAnswer        = &ErrorData{Code: CodeUnexpectedAnswer, Reason: "Unexpected ANSWER (no OFFER)"}
+	ErrTokenNotFound           = &ErrorData{Code: CodeTokenNotFound, Reason: "Token not found"}
+	ErrWebRTCState             = &ErrorData{Code: CodeWebRTCState, Reason: "Wrong WebRTC state"}
+	ErrNotAcceptingSessions    = &ErrorData{Code: CodeNotAcceptingSessions, Reason: "Currently not accepting new sessions"}
+	ErrUnknown                 = &ErrorData{Code: CodeUnknown, Reason: "Unknown error"}
+)
+
+func (err *ErrorData) Error() string {
+	return err.Reason
+}
+
+// Is reports whether target is an *ErrorData with the same Code, which makes
+// errors.Is match the sentinel errors.
+func (err *ErrorMsg) Is(target error) bool {
+	t, ok := target.(*ErrorData)
+	return ok && t.Code == err.Err.Code
+}
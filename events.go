@@ -0,0 +1,211 @@
+package janus
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// defaultEventQueueSize is the number of events queued for a Handle or
+// Session on top of the buffer of its Events channel.
+const defaultEventQueueSize = 64
+
+// OverflowPolicy decides what happens to an event for a Handle or Session
+// whose event queue is full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock makes the receive loop wait until the consumer makes
+	// room, so no event is lost. While it waits, no response or event of
+	// any session is delivered, so a single Events channel left unread
+	// stalls the whole Gateway, keep-alives included. Only use it when every
+	// Events channel is always read. Events still queued when the Handle or
+	// Session goes are delivered before its Events channel is closed.
+	OverflowBlock OverflowPolicy = iota
+
+	// OverflowDropOldest discards the oldest queued event to make room for
+	// the new one. This is the default.
+	OverflowDropOldest
+
+	// OverflowError discards the new event. Once the consumer has read the
+	// events queued before, an *EventsDroppedError reports the loss.
+	OverflowError
+)
+
+// EventsDroppedError is delivered on the Events channel of a Handle or
+// Session with the OverflowError policy, after events had to be discarded
+// because the queue was full.
+type EventsDroppedError struct {
+	Dropped int
+}
+
+func (err *EventsDroppedError) Error() string {
+	return fmt.Sprintf("%d events dropped, event queue full", err.Dropped)
+}
+
+// eventQueue delivers events in order to an Events channel. Events are
+// queued by the receive loop and sent to the channel by a dedicated
+// goroutine, so a slow consumer only delays its own events, unless its queue
+// is full and the policy is OverflowBlock.
+type eventQueue struct {
+	out    chan interface{}
+	size   int
+	policy OverflowPolicy
+	logger Logger
+	// keyvals identify the owner of the queue in log messages
+	keyvals []interface{}
+	// dispatch, if set, passes every event to the registered callbacks and
+	// reports whether there are any, in which case out may be left behind
+	dispatch func(interface{}) bool
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	items  []interface{}
+	lost   int
+	closed bool
+	quit   chan struct{}
+
+	dropped uint64
+}
+
+func newEventQueue(out chan interface{}, dispatch func(interface{}) bool, o *options, keyvals ...interface{}) *eventQueue {
+	q := &eventQueue{
+		out:      out,
+		dispatch: dispatch,
+		size:     o.eventQueueSize,
+		policy:   o.overflowPolicy,
+		logger:   o.logger,
+		keyvals:  keyvals,
+		quit:     make(chan struct{}),
+	}
+	q.cond = sync.NewCond(&q.mu)
+	go q.pump()
+	return q
+}
+
+// push queues an event, applying the overflow policy if the queue is full.
+// Events pushed after close are discarded.
+func (q *eventQueue) push(event interface{}) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for !q.closed && len(q.items) >= q.size {
+		switch q.policy {
+		case OverflowDropOldest:
+			q.items = q.items[1:]
+			q.drop("dropped oldest event, event queue full")
+		case OverflowError:
+			q.lost++
+			q.drop("dropped event, event queue full")
+			return
+		default:
+			q.cond.Wait()
+		}
+	}
+	if q.closed {
+		return
+	}
+
+	q.items = append(q.items, event)
+	q.cond.Broadcast()
+}
+
+func (q *eventQueue) drop(msg string) {
+	atomic.AddUint64(&q.dropped, 1)
+	q.logger.Warn(msg, q.keyvals...)
+}
+
+// pump sends queued events to the Events channel until the queue is closed.
+// Remaining events are then delivered under the overflow policy, and the
+// channel is closed.
+func (q *eventQueue) pump() {
+	for {
+		q.mu.Lock()
+		for !q.closed && len(q.items) == 0 && q.lost == 0 {
+			q.cond.Wait()
+		}
+		if q.closed {
+			items := q.items
+			q.items = nil
+			q.mu.Unlock()
+
+			for _, event := range items {
+				if q.callback(event) {
+					q.offer(event)
+					continue
+				}
+				q.deliver(event)
+			}
+			close(q.out)
+			return
+		}
+
+		var event interface{}
+		if len(q.items) > 0 {
+			event = q.items[0]
+			q.items = q.items[1:]
+		} else {
+			event = &EventsDroppedError{Dropped: q.lost}
+			q.lost = 0
+		}
+		q.cond.Broadcast()
+		q.mu.Unlock()
+
+		if q.callback(event) {
+			// Callbacks are in use, the channel might not be drained
+			q.offer(event)
+			continue
+		}
+
+		// Wait for the consumer, unless the queue gets closed meanwhile
+		select {
+		case q.out <- event:
+		case <-q.quit:
+			q.deliver(event)
+		}
+	}
+}
+
+// offer sends event to the Events channel if it has room and reports whether
+// it did.
+func (q *eventQueue) offer(event interface{}) bool {
+	select {
+	case q.out <- event:
+		return true
+	default:
+		return false
+	}
+}
+
+// deliver sends an event left over when the queue closed. OverflowBlock
+// waits for the consumer, the other policies drop the event if the Events
+// channel is full.
+func (q *eventQueue) deliver(event interface{}) {
+	if q.policy == OverflowBlock {
+		q.out <- event
+		return
+	}
+	if !q.offer(event) {
+		q.drop("dropped event, event queue closed")
+	}
+}
+
+// callback passes event to dispatch and reports whether callbacks are in
+// use.
+func (q *eventQueue) callback(event interface{}) bool {
+	return q.dispatch != nil && q.dispatch(event)
+}
+
+func (q *eventQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.closed {
+		q.closed = true
+		close(q.quit)
+		q.cond.Broadcast()
+	}
+}
+
+func (q *eventQueue) droppedEvents() uint64 {
+	return atomic.LoadUint64(&q.dropped)
+}
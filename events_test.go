@@ -0,0 +1,196 @@
+package janus
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/timsolov/janus-go/janustest"
+)
+
+func newTestQueue(size int, policy OverflowPolicy) (*eventQueue, chan interface{}) {
+	out := make(chan interface{})
+	o := newOptions([]Option{WithEventQueue(size, policy)})
+	return newEventQueue(out, nil, o), out
+}
+
+func receive(t *testing.T, out chan interface{}) interface{} {
+	t.Helper()
+	select {
+	case event := <-out:
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("no event delivered")
+		return nil
+	}
+}
+
+func TestEventQueue_Order(t *testing.T) {
+	q, out := newTestQueue(4, OverflowBlock)
+	defer q.close()
+
+	go func() {
+		for i := 0; i < 100; i++ {
+			q.push(i)
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		if event := receive(t, out); event != i {
+			t.Fatalf("expected event %d, got %v", i, event)
+		}
+	}
+	if q.droppedEvents() != 0 {
+		t.Errorf("expected no dropped events, got %d", q.droppedEvents())
+	}
+}
+
+func TestEventQueue_DropOldest(t *testing.T) {
+	q, out := newTestQueue(2, OverflowDropOldest)
+	defer q.close()
+
+	// The pump takes the first event and waits for the consumer with it
+	q.push(0)
+	time.Sleep(50 * time.Millisecond)
+	for i := 1; i <= 4; i++ {
+		q.push(i)
+	}
+
+	for _, expected := range []int{0, 3, 4} {
+		if event := receive(t, out); event != expected {
+			t.Fatalf("expected event %d, got %v", expected, event)
+		}
+	}
+	if q.droppedEvents() != 2 {
+		t.Errorf("expected 2 dropped events, got %d", q.droppedEvents())
+	}
+}
+
+func TestEventQueue_Error(t *testing.T) {
+	q, out := newTestQueue(2, OverflowError)
+	defer q.close()
+
+	q.push(0)
+	time.Sleep(50 * time.Millisecond)
+	for i := 1; i <= 4; i++ {
+		q.push(i)
+	}
+
+	if event := receive(t, out); event != 0 {
+		t.Fatalf("expected event 0, got %v", event)
+	}
+	if event := receive(t, out); event != 1 {
+		t.Fatalf("expected event 1, got %v", event)
+	}
+	// The queue is drained before the consumer learns about the loss
+	if event := receive(t, out); event != 2 {
+		t.Fatalf("expected event 2, got %v", event)
+	}
+	event := receive(t, out)
+	dropped, ok := event.(*EventsDroppedError)
+	if !ok {
+		t.Fatalf("expected *EventsDroppedError, got %#v", event)
+	}
+	if dropped.Dropped != 2 || q.droppedEvents() != 2 {
+		t.Errorf("expected 2 dropped events, got %d and %d", dropped.Dropped, q.droppedEvents())
+	}
+}
+
+func TestEventQueue_Close(t *testing.T) {
+	q, out := newTestQueue(2, OverflowBlock)
+	q.close()
+	q.close()
+	q.push(0)
+
+	select {
+	case _, ok := <-out:
+		if ok {
+			t.Error("expected closed channel")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Events not closed")
+	}
+}
+
+func TestEventQueue_CloseBacklog(t *testing.T) {
+	q, out := newTestQueue(16, OverflowBlock)
+	for i := 0; i < 10; i++ {
+		q.push(i)
+	}
+	q.close()
+
+	for i := 0; i < 10; i++ {
+		if event := receive(t, out); event != i {
+			t.Fatalf("expected event %d, got %v", i, event)
+		}
+	}
+	if _, ok := <-out; ok {
+		t.Error("expected closed channel")
+	}
+	if q.droppedEvents() != 0 {
+		t.Errorf("expected no dropped events, got %d", q.droppedEvents())
+	}
+
+	// The drop policies count the events which do not fit in the channel
+	out = make(chan interface{}, 2)
+	q = newEventQueue(out, nil, newOptions([]Option{WithEventQueue(16, OverflowDropOldest)}))
+	for i := 0; i < 10; i++ {
+		q.push(i)
+	}
+	q.close()
+	// Let the pump finish before making room in the channel
+	for deadline := time.Now().Add(time.Second); q.droppedEvents() < 8 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	for i := 0; i < 2; i++ {
+		if event := receive(t, out); event != i {
+			t.Fatalf("expected event %d, got %v", i, event)
+		}
+	}
+	if _, ok := <-out; ok {
+		t.Error("expected closed channel")
+	}
+	if q.droppedEvents() != 8 {
+		t.Errorf("expected 8 dropped events, got %d", q.droppedEvents())
+	}
+}
+
+func TestGateway_UnreadEvents(t *testing.T) {
+	server := janustest.NewServer()
+	defer server.Close()
+
+	gateway, err := Connect(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gateway.Close()
+
+	idle, err := gateway.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	handle, err := idle.Attach("janus.plugin.echotest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	busy, err := gateway.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Nobody reads the events of handle
+	for i := 0; i < 100; i++ {
+		if err := server.WebRTCUp(idle.ID, handle.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := busy.KeepAliveContext(ctx); err != nil {
+		t.Fatalf("keepalive of another session failed: %v", err)
+	}
+	// The keepalive response comes after the events, which are all routed
+	if dropped := handle.DroppedEvents(); dropped == 0 {
+		t.Error("expected events to be dropped")
+	}
+}
@@ -0,0 +1,138 @@
+package janus
+
+import "sync"
+
+// handleHandlers holds the callbacks registered on a Handle.
+type handleHandlers struct {
+	mu sync.Mutex
+	handleCallbacks
+}
+
+type handleCallbacks struct {
+	webrtcUp    func(*WebRTCUpMsg)
+	hangup      func(*HangupMsg)
+	media       func(*MediaMsg)
+	slowLink    func(*SlowLinkMsg)
+	pluginEvent func(*EventMsg)
+	detached    func(*DetachedMsg)
+}
+
+// OnWebRTCUp registers f to be called when the PeerConnection of this handle
+// is up. Callbacks of a handle are called one at a time, in the order the
+// events were received, before the event is passed to the Events channel.
+// Once any callback is registered, events are passed to the Events channel
+// only while it has room, so it does not need to be drained. Registering a
+// nil f removes the callback.
+func (handle *Handle) OnWebRTCUp(f func(*WebRTCUpMsg)) {
+	handle.handlers.mu.Lock()
+	handle.handlers.webrtcUp = f
+	handle.handlers.mu.Unlock()
+}
+
+// OnHangup registers f to be called when the PeerConnection of this handle
+// is closed. See OnWebRTCUp for how callbacks are called.
+func (handle *Handle) OnHangup(f func(*HangupMsg)) {
+	handle.handlers.mu.Lock()
+	handle.handlers.hangup = f
+	handle.handlers.mu.Unlock()
+}
+
+// OnMedia registers f to be called when the Gateway starts or stops
+// receiving media of a type on this handle. See OnWebRTCUp for how callbacks
+// are called.
+func (handle *Handle) OnMedia(f func(*MediaMsg)) {
+	handle.handlers.mu.Lock()
+	handle.handlers.media = f
+	handle.handlers.mu.Unlock()
+}
+
+// OnSlowLink registers f to be called when the Gateway reports packet loss
+// on this handle. See OnWebRTCUp for how callbacks are called.
+func (handle *Handle) OnSlowLink(f func(*SlowLinkMsg)) {
+	handle.handlers.mu.Lock()
+	handle.handlers.slowLink = f
+	handle.handlers.mu.Unlock()
+}
+
+// OnPluginEvent registers f to be called for every event sent by the plugin
+// of this handle. See OnWebRTCUp for how callbacks are called.
+func (handle *Handle) OnPluginEvent(f func(*EventMsg)) {
+	handle.handlers.mu.Lock()
+	handle.handlers.pluginEvent = f
+	handle.handlers.mu.Unlock()
+}
+
+// OnDetached registers f to be called when the Gateway reports this handle
+// as detached. See OnWebRTCUp for how callbacks are called.
+func (handle *Handle) OnDetached(f func(*DetachedMsg)) {
+	handle.handlers.mu.Lock()
+	handle.handlers.detached = f
+	handle.handlers.mu.Unlock()
+}
+
+// dispatch calls the callback registered for event, if any, and reports
+// whether callbacks are in use on this handle.
+func (h *handleHandlers) dispatch(event interface{}) bool {
+	h.mu.Lock()
+	handlers := h.handleCallbacks
+	h.mu.Unlock()
+
+	switch msg := event.(type) {
+	case *WebRTCUpMsg:
+		if handlers.webrtcUp != nil {
+			handlers.webrtcUp(msg)
+		}
+	case *HangupMsg:
+		if handlers.hangup != nil {
+			handlers.hangup(msg)
+		}
+	case *MediaMsg:
+		if handlers.media != nil {
+			handlers.media(msg)
+		}
+	case *SlowLinkMsg:
+		if handlers.slowLink != nil {
+			handlers.slowLink(msg)
+		}
+	case *EventMsg:
+		if handlers.pluginEvent != nil {
+			handlers.pluginEvent(msg)
+		}
+	case *DetachedMsg:
+		if handlers.detached != nil {
+			handlers.detached(msg)
+		}
+	}
+
+	return handlers.webrtcUp != nil || handlers.hangup != nil || handlers.media != nil ||
+		handlers.slowLink != nil || handlers.pluginEvent != nil || handlers.detached != nil
+}
+
+// sessionHandlers holds the callbacks registered on a Session.
+type sessionHandlers struct {
+	mu      sync.Mutex
+	timeout func(*TimeoutMsg)
+}
+
+// OnTimeout registers f to be called when the Gateway reports this session
+// as timed out. Like the callbacks of a Handle, f is called before the event
+// is passed to the Events channel, which then does not need to be drained.
+// Registering a nil f removes the callback.
+func (session *Session) OnTimeout(f func(*TimeoutMsg)) {
+	session.handlers.mu.Lock()
+	session.handlers.timeout = f
+	session.handlers.mu.Unlock()
+}
+
+// dispatch calls the callback registered for event, if any, and reports
+// whether callbacks are in use on this session.
+func (h *sessionHandlers) dispatch(event interface{}) bool {
+	h.mu.Lock()
+	timeout := h.timeout
+	h.mu.Unlock()
+
+	if msg, ok := event.(*TimeoutMsg); ok && timeout != nil {
+		timeout(msg)
+	}
+	return timeout != nil
+}
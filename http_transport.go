@@ -0,0 +1,315 @@
+package janus
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxEvents is the maximum number of events requested by a single long poll.
+const maxEvents = 10
+
+var errHTTPTransportClosed = errors.New("http transport closed")
+
+// HttpTransport implements the Janus REST API. Every request is POSTed to
+// /janus, /janus/<session> or /janus/<session>/<handle> and the response body
+// is received as a frame. The requests of a session are POSTed one at a time,
+// in the order they are sent. Events are fetched by one long poll per
+// session, started when a create request succeeds and stopped by destroy.
+type HttpTransport struct {
+	url    string
+	client *http.Client
+	header http.Header
+	logger Logger
+	frames chan []byte
+
+	closed    chan struct{}
+	closeOnce sync.Once
+
+	mu    sync.Mutex
+	polls map[uint64]chan struct{}
+	// posts queues the requests of each session, and of the requests
+	// without session under 0, while a worker POSTs them
+	posts map[uint64][]*httpPost
+}
+
+// httpPost is a request waiting to be POSTed.
+type httpPost struct {
+	endpoint string
+	data     []byte
+	req      httpRequest
+}
+
+// httpRequest holds the fields of a request frame needed to route it.
+type httpRequest struct {
+	Type        string `json:"janus"`
+	Transaction string `json:"transaction"`
+	Session     uint64 `json:"session_id"`
+	Handle      uint64 `json:"handle_id"`
+	Token       string `json:"token"`
+	APISecret   string `json:"apisecret"`
+}
+
+// NewHttpTransport connects to the Janus REST API at url, e.g.
+// http://localhost:8088/janus, and checks it is reachable with an info
+// request. WithTLSConfig, WithHeader, WithProxy and WithHandshakeTimeout
+// apply.
+func NewHttpTransport(url string, opts ...Option) (*HttpTransport, error) {
+	return newHttpTransport(url, newOptions(opts))
+}
+
+func newHttpTransport(url string, o *options) (*HttpTransport, error) {
+	t := new(HttpTransport)
+	t.url = strings.TrimSuffix(url, "/")
+	t.client = o.httpClient()
+	t.header = o.header
+	t.logger = o.logger
+	t.frames = make(chan []byte, maxEvents)
+	t.closed = make(chan struct{})
+	t.polls = make(map[uint64]chan struct{})
+	t.posts = make(map[uint64][]*httpPost)
+
+	if _, err := t.do(context.Background(), http.MethodGet, t.url+"/info", nil); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+func (t *HttpTransport) Send(data []byte) error {
+	select {
+	case <-t.closed:
+		return errHTTPTransportClosed
+	default:
+	}
+
+	post := &httpPost{endpoint: t.url, data: data}
+	req := &post.req
+	if err := json.Unmarshal(data, req); err != nil {
+		return fmt.Errorf("json.Unmarshal: %w", err)
+	}
+	if req.Session != 0 {
+		post.endpoint += "/" + strconv.FormatUint(req.Session, 10)
+		if req.Handle != 0 {
+			post.endpoint += "/" + strconv.FormatUint(req.Handle, 10)
+		}
+	}
+
+	// Responses are received as frames, so requests must not block the
+	// Gateway's writer. They are queued for the worker of their session,
+	// started unless it is running.
+	t.mu.Lock()
+	queue, running := t.posts[req.Session]
+	t.posts[req.Session] = append(queue, post)
+	t.mu.Unlock()
+	if !running {
+		go t.postAll(req.Session)
+	}
+	return nil
+}
+
+// postAll POSTs the queued requests of session in order, until the queue is
+// empty or the transport is closed.
+func (t *HttpTransport) postAll(session uint64) {
+	for {
+		t.mu.Lock()
+		queue := t.posts[session]
+		closed := false
+		select {
+		case <-t.closed:
+			closed = true
+		default:
+		}
+		if len(queue) == 0 || closed {
+			delete(t.posts, session)
+			t.mu.Unlock()
+			return
+		}
+		post := queue[0]
+		t.posts[session] = queue[1:]
+		t.mu.Unlock()
+
+		t.post(post.endpoint, post.data, &post.req)
+	}
+}
+
+func (t *HttpTransport) post(endpoint string, data []byte, req *httpRequest) {
+	body, err := t.do(context.Background(), http.MethodPost, endpoint, data)
+	if err != nil {
+		// Turn the failure into an error response for the requester, as
+		// Janus does for transport errors
+		body, _ = json.Marshal(map[string]interface{}{
+			"janus":       "error",
+			"transaction": req.Transaction,
+			"error": map[string]interface{}{
+				"code":   CodeTransportSpecific,
+				"reason": err.Error(),
+			},
+		})
+		t.push(body)
+		return
+	}
+
+	switch req.Type {
+	case "create":
+		var success SuccessMsg
+		if err := json.Unmarshal(body, &success); err == nil && success.Data.ID != 0 {
+			t.startPoll(success.Data.ID, req.Token, req.APISecret)
+		}
+	case "destroy":
+		t.stopPoll(req.Session)
+	}
+
+	t.push(body)
+}
+
+func (t *HttpTransport) do(ctx context.Context, method, endpoint string, data []byte) ([]byte, error) {
+	req, err := http.NewRequest(method, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	for key, values := range t.header {
+		req.Header[key] = values
+	}
+	if data != nil {
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return nil, fmt.Errorf("%s %s: %s", method, endpoint, resp.Status)
+	}
+
+	return ioutil.ReadAll(resp.Body)
+}
+
+func (t *HttpTransport) push(frame []byte) {
+	select {
+	case t.frames <- frame:
+	case <-t.closed:
+	}
+}
+
+func (t *HttpTransport) startPoll(session uint64, token, apiSecret string) {
+	stop := make(chan struct{})
+
+	t.mu.Lock()
+	t.polls[session] = stop
+	t.mu.Unlock()
+
+	go t.poll(session, token, apiSecret, stop)
+}
+
+func (t *HttpTransport) stopPoll(session uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if stop, ok := t.polls[session]; ok {
+		close(stop)
+		delete(t.polls, session)
+	}
+}
+
+// poll long polls the events of session until stop or the transport is
+// closed, or the server reports the session gone.
+func (t *HttpTransport) poll(session uint64, token, apiSecret string, stop chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-stop:
+		case <-t.closed:
+		}
+		cancel()
+	}()
+
+	query := url.Values{}
+	query.Set("maxev", strconv.Itoa(maxEvents))
+	if token != "" {
+		query.Set("token", token)
+	}
+	if apiSecret != "" {
+		query.Set("apisecret", apiSecret)
+	}
+	endpoint := fmt.Sprintf("%s/%d?%s", t.url, session, query.Encode())
+
+	for {
+		body, err := t.do(ctx, http.MethodGet, endpoint, nil)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			t.logger.Warn("long poll failed", "session_id", session, "error", err)
+			select {
+			case <-time.After(time.Second):
+			case <-ctx.Done():
+				return
+			}
+			continue
+		}
+
+		var frames []json.RawMessage
+		if len(body) > 0 && body[0] == '[' {
+			if err := json.Unmarshal(body, &frames); err != nil {
+				t.logger.Warn("unable to parse long poll response", "session_id", session, "error", err)
+				continue
+			}
+		} else {
+			frames = []json.RawMessage{body}
+		}
+
+		for _, frame := range frames {
+			var base struct {
+				Type string    `json:"janus"`
+				Err  ErrorData `json:"error"`
+			}
+			if err := json.Unmarshal(frame, &base); err != nil {
+				t.logger.Warn("unable to parse long poll event", "session_id", session, "error", err)
+				continue
+			}
+			switch base.Type {
+			case "keepalive":
+				continue
+			case "error":
+				t.logger.Warn("long poll stopped", "session_id", session, "code", base.Err.Code, "reason", base.Err.Reason)
+				t.stopPoll(session)
+				return
+			}
+			t.push(frame)
+		}
+	}
+}
+
+func (t *HttpTransport) Receive() ([]byte, error) {
+	select {
+	case frame := <-t.frames:
+		return frame, nil
+	case <-t.closed:
+		return nil, errHTTPTransportClosed
+	}
+}
+
+// Ping is a no-op, sessions are kept alive by their long polls.
+func (t *HttpTransport) Ping() error {
+	return nil
+}
+
+func (t *HttpTransport) Close() error {
+	t.closeOnce.Do(func() {
+		close(t.closed)
+	})
+	return nil
+}
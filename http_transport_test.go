@@ -0,0 +1,175 @@
+package janus
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGateway_HTTP(t *testing.T) {
+	events := make(chan map[string]interface{}, 1)
+	var mu sync.Mutex
+	var polls []string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			switch r.URL.Path {
+			case "/janus/info":
+				json.NewEncoder(w).Encode(map[string]interface{}{"janus": "server_info"})
+			case "/janus/1":
+				mu.Lock()
+				polls = append(polls, r.URL.RawQuery)
+				mu.Unlock()
+				select {
+				case event := <-events:
+					json.NewEncoder(w).Encode([]interface{}{event})
+				case <-r.Context().Done():
+				}
+			default:
+				http.NotFound(w, r)
+			}
+			return
+		}
+
+		var req map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+			return
+		}
+		resp := map[string]interface{}{"transaction": req["transaction"]}
+		switch r.URL.Path + " " + req["janus"].(string) {
+		case "/janus create":
+			resp["janus"] = "success"
+			resp["data"] = map[string]interface{}{"id": 1}
+		case "/janus/1 attach":
+			resp["janus"] = "success"
+			resp["data"] = map[string]interface{}{"id": 2}
+		case "/janus/1/2 message":
+			resp["janus"] = "ack"
+			events <- map[string]interface{}{
+				"janus":      "event",
+				"session_id": 1,
+				"sender":     2,
+				"plugindata": map[string]interface{}{
+					"plugin": "janus.plugin.echotest",
+					"data":   map[string]interface{}{"result": "ok"},
+				},
+			}
+		case "/janus/1 destroy":
+			resp["janus"] = "ack"
+		default:
+			resp["janus"] = "error"
+			resp["error"] = map[string]interface{}{"code": 457, "reason": "Unhandled request " + r.URL.Path}
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer srv.Close()
+
+	gateway, err := Connect(srv.URL + "/janus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gateway.Close()
+	gateway.Token = "secret"
+
+	session, err := gateway.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	handle, err := session.Attach("janus.plugin.echotest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if handle.ID != 2 {
+		t.Errorf("expected handle 2, got %d", handle.ID)
+	}
+
+	if _, err = handle.Trickle(map[string]interface{}{"completed": true}); err == nil {
+		t.Error("expected error response to unhandled trickle")
+	}
+
+	// The ack is the only response, the event arrives through the long poll
+	req, ch := newRequest("message")
+	req["body"] = map[string]interface{}{"audio": true}
+	if _, err = handle.send(req, ch); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case msg := <-handle.Events:
+		event, ok := msg.(*EventMsg)
+		if !ok {
+			t.Fatalf("expected *EventMsg, got %#v", msg)
+		}
+		if event.Plugindata.Data["result"] != "ok" {
+			t.Errorf("unexpected event data %v", event.Plugindata.Data)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("event not received through long poll")
+	}
+
+	mu.Lock()
+	query := polls[0]
+	mu.Unlock()
+	if query != "maxev=10&token=secret" {
+		t.Errorf("unexpected long poll query %s", query)
+	}
+
+	if _, err = session.Destroy(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHttpTransport_Order(t *testing.T) {
+	var mu sync.Mutex
+	var order []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			return
+		}
+		var req httpRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+			return
+		}
+		// The first request is the slowest
+		if req.Transaction == "1" {
+			time.Sleep(50 * time.Millisecond)
+		}
+		mu.Lock()
+		order = append(order, req.Transaction)
+		mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]interface{}{"janus": "ack", "transaction": req.Transaction})
+	}))
+	defer srv.Close()
+
+	transport, err := NewHttpTransport(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer transport.Close()
+
+	transactions := []string{"1", "2", "3", "4", "5"}
+	for _, transaction := range transactions {
+		frame, _ := json.Marshal(map[string]interface{}{"janus": "message", "transaction": transaction, "session_id": 1, "handle_id": 2})
+		if err := transport.Send(frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for range transactions {
+		if _, err := transport.Receive(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for i, transaction := range transactions {
+		if order[i] != transaction {
+			t.Fatalf("expected requests %v, got %v", transactions, order)
+		}
+	}
+}
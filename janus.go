@@ -3,19 +3,15 @@
 package janus
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"log"
 	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
-
-	"github.com/gorilla/websocket"
 )
 
-var debug = false
-
 func unexpected(request string) error {
 	return fmt.Errorf("Unexpected response received to '%s' request", request)
 }
@@ -23,174 +19,585 @@ func unexpected(request string) error {
 func newRequest(method string) (map[string]interface{}, chan interface{}) {
 	req := make(map[string]interface{}, 8)
 	req["janus"] = method
-	return req, make(chan interface{})
+	// Leave room for the ack and the reply, so that a late response to an
+	// abandoned transaction never blocks the delivering goroutine.
+	return req, make(chan interface{}, 2)
 }
 
 // Gateway represents a connection to an instance of the Janus Gateway.
 type Gateway struct {
-	// Sessions is a map of the currently active sessions to the gateway.
-	Sessions map[uint64]*Session
-
-	// Stored token to use for authentication.
+	// Stored token to use for authentication. Sessions and handles can
+	// override it with their own Token.
 	// See https://janus.conf.meetecho.com/docs/auth.html#token
 	Token string
 
-	// Access to the Sessions map should be synchronized with the Gateway.Lock()
-	// and Gateway.Unlock() methods provided by the embedded sync.Mutex.
-	sync.Mutex
+	// APISecret is the shared secret added to every request when the
+	// Gateway is configured with an api_secret.
+	// See https://janus.conf.meetecho.com/docs/auth.html#secret
+	APISecret string
 
-	conn            *websocket.Conn
+	// mu guards sessions, transactions and err
+	mu       sync.Mutex
+	sessions map[uint64]*Session
+
+	transport       Transport
 	nextTransaction uint64
 	transactions    map[uint64]chan interface{}
 
-	sendChan chan []byte
-	writeMu  sync.Mutex
+	// sendq holds the frames waiting to be written by the writer goroutine,
+	// writeMu guards the transport while it is swapped on reconnect
+	sendq   chan outgoing
+	writeMu sync.Mutex
+
+	// done is closed when the connection to the Gateway is lost, err holds
+	// the reason.
+	done chan struct{}
+	err  error
+
+	dial      DialFunc
+	options   *options
+	closing   chan struct{}
+	closeOnce sync.Once
+
+	shutdownOnce sync.Once
+	shutdownErr  error
+
+	keepAliveMu       sync.Mutex
+	keepAliveInterval time.Duration
+}
+
+// Connect connects to the Janus Gateway at url. ws:// and wss:// URLs use
+// the WebSocket transport, http:// and https:// URLs use the REST transport
+// with one long poll per session to receive events, and unix:// URLs use the
+// Unix Sockets transport listening on the URL path.
+func Connect(url string, opts ...Option) (*Gateway, error) {
+	o := newOptions(opts)
+	return connect(func() (Transport, error) {
+		return dialURL(url, o)
+	}, o)
 }
 
-func Connect(wsURL string) (*Gateway, error) {
-	websocket.DefaultDialer.Subprotocols = []string{"janus-protocol"}
+// ConnectTransport connects to the Janus Gateway through the Transport
+// returned by dial. dial is called again on every reconnect attempt when the
+// Gateway is created WithReconnect.
+func ConnectTransport(dial DialFunc, opts ...Option) (*Gateway, error) {
+	return connect(dial, newOptions(opts))
+}
 
-	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+func connect(dial DialFunc, o *options) (*Gateway, error) {
+	if o.recorder != nil {
+		dial = recordDial(dial, o.recorder)
+	}
+
+	gateway := new(Gateway)
+	gateway.dial = dial
+	gateway.options = o
 
+	transport, err := dial()
 	if err != nil {
 		return nil, err
 	}
 
-	gateway := new(Gateway)
-	gateway.conn = conn
+	gateway.transport = transport
 	gateway.transactions = make(map[uint64]chan interface{})
-	gateway.Sessions = make(map[uint64]*Session)
+	gateway.sessions = make(map[uint64]*Session)
+	gateway.done = make(chan struct{})
+	gateway.closing = make(chan struct{})
 
-	gateway.sendChan = make(chan []byte, 100)
+	gateway.sendq = make(chan outgoing, o.sendQueueSize)
 
-	go gateway.ping()
+	go gateway.writer()
 	go gateway.recv()
 	return gateway, nil
 }
 
-// Close closes the underlying connection to the Gateway.
+func (gateway *Gateway) log() Logger {
+	return gateway.options.logger
+}
+
+// Close closes the underlying connection to the Gateway, leaving the
+// sessions to expire on the server, see Shutdown. A Gateway created with
+// WithReconnect does not redial after Close.
 func (gateway *Gateway) Close() error {
-	return gateway.conn.Close()
+	gateway.closeOnce.Do(func() {
+		close(gateway.closing)
+	})
+
+	gateway.writeMu.Lock()
+	transport := gateway.transport
+	gateway.writeMu.Unlock()
+	return transport.Close()
+}
+
+// Shutdown gracefully closes the connection to the Gateway: it detaches all
+// handles and destroys all sessions, waits for the pending requests to
+// complete, closes the connection and waits for the background goroutines
+// to stop. If ctx is done first, the connection is closed anyway and the
+// error of ctx is returned. Otherwise the first error of a detach or destroy
+// request is returned, if any. Shutdown is safe to call more than once, and
+// later calls return the result of the first one.
+func (gateway *Gateway) Shutdown(ctx context.Context) error {
+	gateway.shutdownOnce.Do(func() {
+		gateway.shutdownErr = gateway.shutdown(ctx)
+	})
+	return gateway.shutdownErr
+}
+
+func (gateway *Gateway) shutdown(ctx context.Context) error {
+	// Do not redial while tearing down
+	gateway.closeOnce.Do(func() {
+		close(gateway.closing)
+	})
+
+	var first error
+	record := func(err error) {
+		if err != nil && first == nil {
+			first = err
+		}
+	}
+
+	for _, session := range gateway.SessionsSnapshot() {
+		for _, handle := range session.HandlesSnapshot() {
+			_, err := handle.DetachContext(ctx)
+			record(err)
+		}
+		_, err := session.DestroyContext(ctx)
+		record(err)
+	}
+
+	// Wait for requests of other goroutines
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+wait:
+	for {
+		gateway.mu.Lock()
+		pending := len(gateway.transactions)
+		gateway.mu.Unlock()
+		if pending == 0 {
+			break
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			break wait
+		case <-gateway.done:
+			break wait
+		}
+	}
+
+	gateway.Close()
+
+	// recv closes done once it has stopped, which stops the other goroutines
+	select {
+	case <-gateway.done:
+	case <-ctx.Done():
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return first
+}
+
+// Done returns a channel that is closed when the connection to the Gateway
+// is lost, either because of a network failure or because Close was called.
+// Once Done is closed all pending and subsequent requests fail, and the
+// Events channels of every known Session and Handle are closed.
+func (gateway *Gateway) Done() <-chan struct{} {
+	return gateway.done
+}
+
+// Err returns nil while the connection to the Gateway is alive. After Done
+// is closed, Err returns an error wrapping ErrConnectionClosed and the cause
+// of the disconnect.
+func (gateway *Gateway) Err() error {
+	gateway.mu.Lock()
+	defer gateway.mu.Unlock()
+	return gateway.err
+}
+
+// disconnect fails every pending transaction, signals Done and then closes
+// the Events channels of all sessions and handles. It is called by recv once
+// reading from the connection fails.
+func (gateway *Gateway) disconnect(cause error) {
+	gateway.log().Info("connection closed", "error", cause)
+
+	gateway.mu.Lock()
+	gateway.err = fmt.Errorf("%w: %v", ErrConnectionClosed, cause)
+	gateway.transactions = make(map[uint64]chan interface{})
+	gateway.mu.Unlock()
+
+	close(gateway.done)
+
+	for _, session := range gateway.SessionsSnapshot() {
+		session.release()
+	}
+}
+
+// failPending fails every pending transaction with an error wrapping
+// ErrConnectionClosed and cause.
+func (gateway *Gateway) failPending(cause error) {
+	err := fmt.Errorf("%w: %v", ErrConnectionClosed, cause)
+
+	gateway.mu.Lock()
+	defer gateway.mu.Unlock()
+	for id, transaction := range gateway.transactions {
+		select {
+		case transaction <- failure{err}:
+		default:
+		}
+		delete(gateway.transactions, id)
+	}
 }
 
-func (gateway *Gateway) send(msg map[string]interface{}, transaction chan interface{}) {
+// reconnect redials the Gateway with backoff after the connection was lost
+// and reclaims all known sessions in the background. It reports false when
+// reconnecting is disabled, the Gateway has been closed or every attempt
+// failed.
+func (gateway *Gateway) reconnect(cause error) bool {
+	config := gateway.options.reconnect
+	if config == nil {
+		return false
+	}
+	select {
+	case <-gateway.closing:
+		return false
+	default:
+	}
+
+	gateway.log().Warn("connection lost, reconnecting", "error", cause)
+	gateway.failPending(cause)
+	// Frames queued for the lost connection belong to failed requests
+	for flushed := false; !flushed; {
+		select {
+		case <-gateway.sendq:
+		default:
+			flushed = true
+		}
+	}
+	if config.OnDisconnect != nil {
+		config.OnDisconnect(cause)
+	}
+
+	backoff := config.MinBackoff
+	for attempt := 1; config.MaxAttempts == 0 || attempt <= config.MaxAttempts; attempt++ {
+		select {
+		case <-time.After(backoff):
+		case <-gateway.closing:
+			return false
+		}
+
+		transport, err := gateway.dial()
+		if err != nil {
+			gateway.log().Warn("reconnect failed", "attempt", attempt, "error", err)
+			if backoff *= 2; backoff > config.MaxBackoff {
+				backoff = config.MaxBackoff
+			}
+			continue
+		}
+
+		gateway.writeMu.Lock()
+		old := gateway.transport
+		gateway.transport = transport
+		gateway.writeMu.Unlock()
+		old.Close()
+
+		// Close might have raced with the redial
+		select {
+		case <-gateway.closing:
+			transport.Close()
+			return false
+		default:
+		}
+
+		gateway.log().Info("reconnected", "attempt", attempt)
+		go gateway.reclaim(config)
+		return true
+	}
+
+	return false
+}
+
+// reclaim claims every known session on the current connection. Sessions
+// that cannot be claimed are released.
+func (gateway *Gateway) reclaim(config *ReconnectConfig) {
+	for _, session := range gateway.SessionsSnapshot() {
+		ctx, cancel := context.WithTimeout(context.Background(), config.ReclaimTimeout)
+		err := session.claim(ctx)
+		cancel()
+		if err == nil {
+			continue
+		}
+
+		session.release()
+		gateway.log().Warn("unable to reclaim session", "session_id", session.ID, "error", err)
+
+		if config.OnReclaimFailed != nil {
+			config.OnReclaimFailed(session, err)
+		}
+	}
+
+	if config.OnReconnect != nil {
+		config.OnReconnect()
+	}
+}
+
+func (gateway *Gateway) send(msg map[string]interface{}, transaction chan interface{}) (uint64, error) {
+	select {
+	case <-gateway.done:
+		return 0, gateway.Err()
+	default:
+	}
+
 	id := atomic.AddUint64(&gateway.nextTransaction, 1)
 
 	msg["transaction"] = strconv.FormatUint(id, 10)
-	gateway.Lock()
+	gateway.mu.Lock()
 	gateway.transactions[id] = transaction
-	gateway.Unlock()
+	gateway.mu.Unlock()
 
-	if gateway.Token != "" {
+	// Sessions and handles may have set their own token already
+	if _, ok := msg["token"]; !ok && gateway.Token != "" {
 		msg["token"] = gateway.Token
 	}
+	if gateway.APISecret != "" {
+		msg["apisecret"] = gateway.APISecret
+	}
 
 	data, err := json.Marshal(msg)
 	if err != nil {
-		fmt.Printf("json.Marshal: %s\n", err)
-		return
+		gateway.forget(id)
+		return 0, fmt.Errorf("json.Marshal: %w", err)
 	}
 
-	gateway.writeMu.Lock()
-	err = gateway.conn.WriteMessage(websocket.TextMessage, data)
-	gateway.writeMu.Unlock()
+	gateway.log().Debug("sending request", "janus", msg["janus"], "transaction", msg["transaction"],
+		"session_id", msg["session_id"], "handle_id", msg["handle_id"])
 
-	if err != nil {
-		fmt.Printf("conn.Write: %s\n", err)
-		return
+	select {
+	case gateway.sendq <- outgoing{id: id, data: data}:
+	default:
+		gateway.forget(id)
+		return 0, ErrSendQueueFull
 	}
+
+	return id, nil
 }
 
-func passMsg(ch chan interface{}, msg interface{}) {
-	ch <- msg
+// outgoing is a frame waiting to be written.
+type outgoing struct {
+	id   uint64
+	data []byte
 }
 
-func (gateway *Gateway) ping() {
-	ticker := time.NewTicker(time.Second * 30)
-	defer ticker.Stop()
-	for {
+// fail fails the pending transaction id with err.
+func (gateway *Gateway) fail(id uint64, err error) {
+	gateway.mu.Lock()
+	transaction := gateway.transactions[id]
+	delete(gateway.transactions, id)
+	gateway.mu.Unlock()
+
+	if transaction != nil {
 		select {
-		case <-ticker.C:
-			err := gateway.conn.WriteControl(websocket.PingMessage, []byte{}, time.Now().Add(20*time.Second))
-			if err != nil {
-				log.Println("ping:", err)
-				return
-			}
+		case transaction <- failure{err}:
+		default:
 		}
 	}
 }
 
-func (gateway *Gateway) sendloop() {
+// forget removes a transaction from the pending transactions map. Responses
+// arriving afterwards for this transaction are discarded.
+func (gateway *Gateway) forget(id uint64) {
+	gateway.mu.Lock()
+	delete(gateway.transactions, id)
+	gateway.mu.Unlock()
+}
+
+// failure is delivered on a transaction channel to fail the request.
+type failure struct {
+	err error
+}
+
+// wait blocks until a message is delivered on the transaction channel or ctx
+// is done, in which case a *TimeoutError is returned. If the connection is
+// lost while waiting, the error reported by Err is returned.
+func (gateway *Gateway) wait(ctx context.Context, request string, transaction chan interface{}) (interface{}, error) {
+	select {
+	case msg := <-transaction:
+		if f, ok := msg.(failure); ok {
+			return nil, f.err
+		}
+		return msg, nil
+	case <-ctx.Done():
+		return nil, &TimeoutError{Request: request, Err: ctx.Err()}
+	case <-gateway.done:
+		return nil, gateway.Err()
+	}
+}
+
+// writer is the only goroutine writing to the transport. It writes the
+// queued frames and, every ping interval, checks the liveness of the
+// connection, until the connection is lost.
+func (gateway *Gateway) writer() {
+	var tick <-chan time.Time
+	if gateway.options.pingInterval > 0 {
+		ticker := time.NewTicker(gateway.options.pingInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
 
+	for {
+		select {
+		case frame := <-gateway.sendq:
+			gateway.writeMu.Lock()
+			transport := gateway.transport
+			gateway.writeMu.Unlock()
+
+			if err := transport.Send(frame.data); err != nil {
+				gateway.log().Error("write failed", "transaction", frame.id, "error", err)
+				gateway.fail(frame.id, fmt.Errorf("send: %w", err))
+				// The connection is unusable after a failed write, recv
+				// notices it is closed and either reconnects or closes done
+				transport.Close()
+			}
+		case <-tick:
+			gateway.writeMu.Lock()
+			transport := gateway.transport
+			gateway.writeMu.Unlock()
+
+			// A failed ping is noticed by recv, which either reconnects or
+			// closes done
+			err := transport.Ping()
+			if err != nil {
+				gateway.log().Warn("ping failed", "error", err)
+			}
+		case <-gateway.done:
+			return
+		}
+	}
 }
 
 func (gateway *Gateway) recv() {
 
 	for {
 		// Read message from Gateway
-		_, data, err := gateway.conn.ReadMessage()
+		data, err := gateway.transport.Receive()
 		if err != nil {
-			fmt.Printf("conn.Read: %s\n", err)
+			if gateway.reconnect(err) {
+				continue
+			}
+			gateway.disconnect(err)
 			return
 		}
 
 		// parse message
 		base, msg, err := ParseMessage(data)
 		if err != nil {
-			fmt.Printf("parse error: %s\n", err)
+			gateway.log().Warn("unable to parse message", "error", err)
 			continue
 		}
 
-		// Pass message on from here
+		// Responses go to the requester, as long as somebody is waiting
+		if gateway.respond(base, msg) {
+			continue
+		}
+		gateway.route(base, msg)
+	}
+}
+
+// respond passes msg to the requester of the transaction it belongs to, and
+// reports whether it was delivered. Responses to transactions that are no
+// longer pending, or whose requester has fallen behind, are left to route.
+func (gateway *Gateway) respond(base *BaseMsg, msg interface{}) bool {
+	if base.ID == "" {
+		return false
+	}
+	id, _ := strconv.ParseUint(base.ID, 10, 64)
+	// Lookup Transaction
+	gateway.mu.Lock()
+	transaction := gateway.transactions[id]
+	gateway.mu.Unlock()
+	if transaction == nil {
+		return false
+	}
+
+	// Pass msg, keeping the order of messages of the same transaction
+	select {
+	case transaction <- msg:
+		return true
+	default:
+		return false
+	}
+}
+
+// route delivers an event to the Handle or Session it was sent for. The
+// local state is updated when the Gateway reports a handle as detached or a
+// session as timed out.
+func (gateway *Gateway) route(base *BaseMsg, msg interface{}) {
+	switch base.Type {
+	case "success", "ack", "error", "server_info":
+		// Nobody is waiting for this response anymore
+		gateway.log().Debug("discarding response to unknown transaction", "transaction", base.ID, "janus", base.Type)
+		return
+	}
+
+	// Lookup Session
+	session, ok := gateway.Session(base.Session)
+	if !ok {
+		gateway.log().Warn("unable to deliver message, session gone", "janus", base.Type, "session_id", base.Session, "handle_id", base.Handle)
+		return
+	}
+
+	if base.Handle == 0 {
 		if base.PluginData.Plugin != "" {
-			// Is this a Handle event?
-			if base.Handle == 0 {
-				// Error()
-			} else {
-				// Lookup Session
-				gateway.Lock()
-				session := gateway.Sessions[base.Session]
-				gateway.Unlock()
-				if session == nil {
-					fmt.Printf("Unable to deliver message. Session gone?\n")
-					continue
-				}
-
-				// Lookup Handle
-				session.Lock()
-				handle := session.Handles[base.Handle]
-				session.Unlock()
-				if handle == nil {
-					fmt.Printf("Unable to deliver message. Handle gone?\n")
-					continue
-				}
-
-				// Pass msg
-				go passMsg(handle.Events, msg)
-			}
-		} else {
-			id, _ := strconv.ParseUint(base.ID, 10, 64)
-			// Lookup Transaction
-			gateway.Lock()
-			transaction := gateway.transactions[id]
-			gateway.Unlock()
-			if transaction == nil {
-				// Error()
-			}
+			gateway.log().Warn("plugin message without sender", "session_id", base.Session, "transaction", base.ID)
+			return
+		}
+
+		session.events.push(msg)
+		if base.Type == "timeout" {
+			session.release()
+		}
+		return
+	}
 
-			// Pass msg
-			go passMsg(transaction, msg)
+	// Lookup Handle
+	handle, ok := session.Handle(base.Handle)
+	if !ok {
+		if base.Type == "detached" {
+			// The handle was removed by a Detach request already
+			gateway.log().Debug("discarding detached event, handle gone", "session_id", base.Session, "handle_id", base.Handle)
+		} else {
+			gateway.log().Warn("unable to deliver message, handle gone", "janus", base.Type, "session_id", base.Session, "handle_id", base.Handle)
 		}
+		return
+	}
+
+	// Pass msg
+	handle.events.push(msg)
+	if base.Type == "detached" {
+		handle.release()
 	}
 }
 
 // Info sends an info request to the Gateway.
 // On success, an InfoMsg will be returned and error will be nil.
 func (gateway *Gateway) Info() (*InfoMsg, error) {
+	return gateway.InfoContext(context.Background())
+}
+
+// InfoContext is like Info but gives up waiting for the response when ctx
+// is done, returning a *TimeoutError.
+func (gateway *Gateway) InfoContext(ctx context.Context) (*InfoMsg, error) {
 	req, ch := newRequest("info")
-	gateway.send(req, ch)
+	id, err := gateway.send(req, ch)
+	if err != nil {
+		return nil, err
+	}
+	defer gateway.forget(id)
 
-	msg := <-ch
+	msg, err := gateway.wait(ctx, "info", ch)
+	if err != nil {
+		return nil, err
+	}
 	switch msg := msg.(type) {
 	case *InfoMsg:
 		return msg, nil
@@ -204,29 +611,66 @@ func (gateway *Gateway) Info() (*InfoMsg, error) {
 // Create sends a create request to the Gateway.
 // On success, a new Session will be returned and error will be nil.
 func (gateway *Gateway) Create() (*Session, error) {
+	return gateway.CreateContext(context.Background())
+}
+
+// CreateContext is like Create but gives up waiting for the response when
+// ctx is done, returning a *TimeoutError.
+func (gateway *Gateway) CreateContext(ctx context.Context) (*Session, error) {
+	return gateway.CreateWithTokenContext(ctx, "")
+}
+
+// CreateWithToken is like Create but authenticates the create request and all
+// requests of the new session with token instead of Gateway.Token.
+func (gateway *Gateway) CreateWithToken(token string) (*Session, error) {
+	return gateway.CreateWithTokenContext(context.Background(), token)
+}
+
+// CreateWithTokenContext is like CreateWithToken but gives up waiting for the
+// response when ctx is done, returning a *TimeoutError.
+func (gateway *Gateway) CreateWithTokenContext(ctx context.Context, token string) (*Session, error) {
 	req, ch := newRequest("create")
-	gateway.send(req, ch)
+	if token != "" {
+		req["token"] = token
+	}
+	id, err := gateway.send(req, ch)
+	if err != nil {
+		return nil, err
+	}
+	defer gateway.forget(id)
 
-	msg := <-ch
+	msg, err := gateway.wait(ctx, "create", ch)
+	if err != nil {
+		return nil, err
+	}
 	var success *SuccessMsg
 	switch msg := msg.(type) {
 	case *SuccessMsg:
 		success = msg
 	case *ErrorMsg:
 		return nil, msg
+	default:
+		return nil, unexpected("create")
 	}
 
 	// Create new session
 	session := new(Session)
 	session.gateway = gateway
 	session.ID = success.Data.ID
-	session.Handles = make(map[uint64]*Handle)
+	session.Token = token
+	session.handles = make(map[uint64]*Handle)
 	session.Events = make(chan interface{}, 2)
+	session.events = newEventQueue(session.Events, session.handlers.dispatch, gateway.options, "session_id", session.ID)
+	session.stop = make(chan struct{})
 
 	// Store this session
-	gateway.Lock()
-	gateway.Sessions[session.ID] = session
-	gateway.Unlock()
+	gateway.mu.Lock()
+	gateway.sessions[session.ID] = session
+	gateway.mu.Unlock()
+
+	if gateway.options.keepAlive {
+		go session.keepAlive()
+	}
 
 	return session, nil
 }
@@ -236,48 +680,84 @@ type Session struct {
 	// ID is the session_id of this session
 	ID uint64
 
-	// Handles is a map of plugin handles within this session
-	Handles map[uint64]*Handle
+	// Token, if set, is used for the requests of this session and its
+	// handles instead of Gateway.Token.
+	Token string
 
+	// Events receives session wide events such as a *TimeoutMsg or a
+	// *KeepAliveError. The channel is closed once the session is destroyed,
+	// timed out or the connection is lost.
 	Events chan interface{}
 
-	// Access to the Handles map should be synchronized with the Session.Lock()
-	// and Session.Unlock() methods provided by the embeded sync.Mutex.
-	sync.Mutex
+	// mu guards handles, state is accessed atomically
+	mu      sync.Mutex
+	handles map[uint64]*Handle
+	state   int32
 
-	gateway *Gateway
+	gateway  *Gateway
+	events   *eventQueue
+	handlers sessionHandlers
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func (session *Session) send(msg map[string]interface{}, transaction chan interface{}) (uint64, error) {
+	if err := session.check(); err != nil {
+		return 0, err
+	}
+	return session.write(msg, transaction)
 }
 
-func (session *Session) send(msg map[string]interface{}, transaction chan interface{}) {
+// write sends a request of this session whatever its state.
+func (session *Session) write(msg map[string]interface{}, transaction chan interface{}) (uint64, error) {
 	msg["session_id"] = session.ID
-	session.gateway.send(msg, transaction)
+	if _, ok := msg["token"]; !ok && session.Token != "" {
+		msg["token"] = session.Token
+	}
+	return session.gateway.send(msg, transaction)
 }
 
 // Attach sends an attach request to the Gateway within this session.
 // plugin should be the unique string of the plugin to attach to.
 // On success, a new Handle will be returned and error will be nil.
 func (session *Session) Attach(plugin string) (*Handle, error) {
+	return session.AttachContext(context.Background(), plugin)
+}
+
+// AttachContext is like Attach but gives up waiting for the response when
+// ctx is done, returning a *TimeoutError.
+func (session *Session) AttachContext(ctx context.Context, plugin string) (*Handle, error) {
 	req, ch := newRequest("attach")
 	req["plugin"] = plugin
-	session.send(req, ch)
+	id, err := session.send(req, ch)
+	if err != nil {
+		return nil, err
+	}
+	defer session.gateway.forget(id)
 
+	msg, err := session.gateway.wait(ctx, "attach", ch)
+	if err != nil {
+		return nil, err
+	}
 	var success *SuccessMsg
-	msg := <-ch
 	switch msg := msg.(type) {
 	case *SuccessMsg:
 		success = msg
 	case *ErrorMsg:
 		return nil, msg
+	default:
+		return nil, unexpected("attach")
 	}
 
 	handle := new(Handle)
 	handle.session = session
 	handle.ID = success.Data.ID
 	handle.Events = make(chan interface{}, 8)
+	handle.events = newEventQueue(handle.Events, handle.handlers.dispatch, session.gateway.options, "session_id", session.ID, "handle_id", handle.ID)
 
-	session.Lock()
-	session.Handles[handle.ID] = handle
-	session.Unlock()
+	session.mu.Lock()
+	session.handles[handle.ID] = handle
+	session.mu.Unlock()
 
 	return handle, nil
 }
@@ -285,10 +765,23 @@ func (session *Session) Attach(plugin string) (*Handle, error) {
 // KeepAlive sends a keep-alive request to the Gateway.
 // On success, an AckMsg will be returned and error will be nil.
 func (session *Session) KeepAlive() (*AckMsg, error) {
+	return session.KeepAliveContext(context.Background())
+}
+
+// KeepAliveContext is like KeepAlive but gives up waiting for the response
+// when ctx is done, returning a *TimeoutError.
+func (session *Session) KeepAliveContext(ctx context.Context) (*AckMsg, error) {
 	req, ch := newRequest("keepalive")
-	session.send(req, ch)
+	id, err := session.send(req, ch)
+	if err != nil {
+		return nil, err
+	}
+	defer session.gateway.forget(id)
 
-	msg := <-ch
+	msg, err := session.gateway.wait(ctx, "keepalive", ch)
+	if err != nil {
+		return nil, err
+	}
 	switch msg := msg.(type) {
 	case *AckMsg:
 		return msg, nil
@@ -299,26 +792,81 @@ func (session *Session) KeepAlive() (*AckMsg, error) {
 	return nil, unexpected("keepalive")
 }
 
+// DroppedEvents returns the number of events discarded because the event
+// queue of this session was full.
+func (session *Session) DroppedEvents() uint64 {
+	return session.events.droppedEvents()
+}
+
+// claim sends a claim request to the Gateway to move this session over to
+// the current connection.
+func (session *Session) claim(ctx context.Context) error {
+	req, ch := newRequest("claim")
+	id, err := session.send(req, ch)
+	if err != nil {
+		return err
+	}
+	defer session.gateway.forget(id)
+
+	msg, err := session.gateway.wait(ctx, "claim", ch)
+	if err != nil {
+		return err
+	}
+	switch msg := msg.(type) {
+	case *SuccessMsg:
+		return nil
+	case *ErrorMsg:
+		return msg
+	}
+
+	return unexpected("claim")
+}
+
 // Destroy sends a destroy request to the Gateway to tear down this session.
-// On success, the Session will be removed from the Gateway.Sessions map, an
-// AckMsg will be returned and error will be nil.
+// On success, the Session will be removed from the Gateway, it and its
+// handles will be gone and their Events channels closed, an AckMsg will be
+// returned and error will be nil. Destroying a session that is already being
+// destroyed or is gone fails with ErrSessionDestroyed.
 func (session *Session) Destroy() (*AckMsg, error) {
+	return session.DestroyContext(context.Background())
+}
+
+// DestroyContext is like Destroy but gives up waiting for the response when
+// ctx is done, returning a *TimeoutError.
+func (session *Session) DestroyContext(ctx context.Context) (*AckMsg, error) {
+	if !begin(&session.state) {
+		return nil, session.unavailable()
+	}
+
 	req, ch := newRequest("destroy")
-	session.send(req, ch)
+	id, err := session.write(req, ch)
+	if err != nil {
+		abort(&session.state)
+		return nil, err
+	}
+	defer session.gateway.forget(id)
 
+	msg, err := session.gateway.wait(ctx, "destroy", ch)
+	if err != nil {
+		abort(&session.state)
+		return nil, err
+	}
 	var ack *AckMsg
-	msg := <-ch
 	switch msg := msg.(type) {
 	case *AckMsg:
 		ack = msg
+	case *SuccessMsg:
+		// Janus confirms with success rather than ack
+		ack = &AckMsg{}
 	case *ErrorMsg:
+		abort(&session.state)
 		return nil, msg
+	default:
+		abort(&session.state)
+		return nil, unexpected("destroy")
 	}
 
-	// Remove this session from the gateway
-	session.gateway.Lock()
-	delete(session.gateway.Sessions, session.ID)
-	session.gateway.Unlock()
+	session.release()
 
 	return ack, nil
 }
@@ -334,28 +882,73 @@ type Handle struct {
 	//User   // Userid
 	User string
 
+	// Token, if set, is used for the requests of this handle instead of
+	// Session.Token or Gateway.Token.
+	Token string
+
 	// Events is a receive only channel that can be used to receive events
-	// related to this handle from the gateway.
+	// related to this handle from the gateway, such as an *EventMsg,
+	// *WebRTCUpMsg, *MediaMsg, *SlowLinkMsg, *HangupMsg or *DetachedMsg.
+	// Events are delivered in order through a bounded queue, see
+	// WithEventQueue, or passed to callbacks registered with OnWebRTCUp and
+	// friends. The channel is closed once the handle is detached or the
+	// connection is lost.
 	Events chan interface{}
 
-	session *Session
+	session  *Session
+	state    int32
+	events   *eventQueue
+	handlers handleHandlers
+
+	// pending holds the transactions of the open PendingMessages, guarded
+	// by the mu of the session
+	pending map[uint64]*PendingMessage
+}
+
+// DroppedEvents returns the number of events discarded because the event
+// queue of this handle was full.
+func (handle *Handle) DroppedEvents() uint64 {
+	return handle.events.droppedEvents()
+}
+
+func (handle *Handle) send(msg map[string]interface{}, transaction chan interface{}) (uint64, error) {
+	if err := handle.check(); err != nil {
+		return 0, err
+	}
+	return handle.write(msg, transaction)
 }
 
-func (handle *Handle) send(msg map[string]interface{}, transaction chan interface{}) {
+// write sends a request of this handle whatever its state.
+func (handle *Handle) write(msg map[string]interface{}, transaction chan interface{}) (uint64, error) {
 	msg["handle_id"] = handle.ID
-	handle.session.send(msg, transaction)
+	if handle.Token != "" {
+		msg["token"] = handle.Token
+	}
+	return handle.session.send(msg, transaction)
 }
 
 // send sync request
 func (handle *Handle) Request(body interface{}) (*SuccessMsg, error) {
+	return handle.RequestContext(context.Background(), body)
+}
+
+// RequestContext is like Request but gives up waiting for the response when
+// ctx is done, returning a *TimeoutError.
+func (handle *Handle) RequestContext(ctx context.Context, body interface{}) (*SuccessMsg, error) {
 	req, ch := newRequest("message")
 	if body != nil {
 		req["body"] = body
 	}
-	handle.send(req, ch)
-
-	msg := <-ch
+	id, err := handle.send(req, ch)
+	if err != nil {
+		return nil, err
+	}
+	defer handle.session.gateway.forget(id)
 
+	msg, err := handle.session.gateway.wait(ctx, "message", ch)
+	if err != nil {
+		return nil, err
+	}
 	switch msg := msg.(type) {
 	case *SuccessMsg:
 		return msg, nil
@@ -371,6 +964,12 @@ func (handle *Handle) Request(body interface{}) (*SuccessMsg, error) {
 // contain an optional SDP offer/answer to establish a WebRTC PeerConnection.
 // On success, an EventMsg will be returned and error will be nil.
 func (handle *Handle) Message(body, jsep interface{}) (*EventMsg, error) {
+	return handle.MessageContext(context.Background(), body, jsep)
+}
+
+// MessageContext is like Message but gives up waiting for the response when
+// ctx is done, returning a *TimeoutError.
+func (handle *Handle) MessageContext(ctx context.Context, body, jsep interface{}) (*EventMsg, error) {
 	req, ch := newRequest("message")
 	if body != nil {
 		req["body"] = body
@@ -378,10 +977,17 @@ func (handle *Handle) Message(body, jsep interface{}) (*EventMsg, error) {
 	if jsep != nil {
 		req["jsep"] = jsep
 	}
-	handle.send(req, ch)
+	id, err := handle.send(req, ch)
+	if err != nil {
+		return nil, err
+	}
+	defer handle.session.gateway.forget(id)
 
 GetMessage: // No tears..
-	msg := <-ch
+	msg, err := handle.session.gateway.wait(ctx, "message", ch)
+	if err != nil {
+		return nil, err
+	}
 	switch msg := msg.(type) {
 	case *AckMsg:
 		goto GetMessage // ..only dreams.
@@ -403,19 +1009,15 @@ GetMessage: // No tears..
 //		}
 // On success, an AckMsg will be returned and error will be nil.
 func (handle *Handle) Trickle(candidate interface{}) (*AckMsg, error) {
+	return handle.TrickleContext(context.Background(), candidate)
+}
+
+// TrickleContext is like Trickle but gives up waiting for the response when
+// ctx is done, returning a *TimeoutError.
+func (handle *Handle) TrickleContext(ctx context.Context, candidate interface{}) (*AckMsg, error) {
 	req, ch := newRequest("trickle")
 	req["candidate"] = candidate
-	handle.send(req, ch)
-
-	msg := <-ch
-	switch msg := msg.(type) {
-	case *AckMsg:
-		return msg, nil
-	case *ErrorMsg:
-		return nil, msg
-	}
-
-	return nil, unexpected("trickle")
+	return handle.trickle(ctx, req, ch)
 }
 
 // TrickleMany sends a trickle request to the Gateway as part of establishing
@@ -423,11 +1025,28 @@ func (handle *Handle) Trickle(candidate interface{}) (*AckMsg, error) {
 // candidates should be an array of ICE candidates.
 // On success, an AckMsg will be returned and error will be nil.
 func (handle *Handle) TrickleMany(candidates interface{}) (*AckMsg, error) {
+	return handle.TrickleManyContext(context.Background(), candidates)
+}
+
+// TrickleManyContext is like TrickleMany but gives up waiting for the
+// response when ctx is done, returning a *TimeoutError.
+func (handle *Handle) TrickleManyContext(ctx context.Context, candidates interface{}) (*AckMsg, error) {
 	req, ch := newRequest("trickle")
 	req["candidates"] = candidates
-	handle.send(req, ch)
+	return handle.trickle(ctx, req, ch)
+}
 
-	msg := <-ch
+func (handle *Handle) trickle(ctx context.Context, req map[string]interface{}, ch chan interface{}) (*AckMsg, error) {
+	id, err := handle.send(req, ch)
+	if err != nil {
+		return nil, err
+	}
+	defer handle.session.gateway.forget(id)
+
+	msg, err := handle.session.gateway.wait(ctx, "trickle", ch)
+	if err != nil {
+		return nil, err
+	}
 	switch msg := msg.(type) {
 	case *AckMsg:
 		return msg, nil
@@ -439,24 +1058,49 @@ func (handle *Handle) TrickleMany(candidates interface{}) (*AckMsg, error) {
 }
 
 // Detach sends a detach request to the Gateway to remove this handle.
-// On success, an AckMsg will be returned and error will be nil.
+// On success, the handle will be gone and its Events channel closed, an
+// AckMsg will be returned and error will be nil. Detaching a handle that is
+// already being detached or is gone fails with ErrHandleDetached.
 func (handle *Handle) Detach() (*AckMsg, error) {
+	return handle.DetachContext(context.Background())
+}
+
+// DetachContext is like Detach but gives up waiting for the response when
+// ctx is done, returning a *TimeoutError.
+func (handle *Handle) DetachContext(ctx context.Context) (*AckMsg, error) {
+	if !begin(&handle.state) {
+		return nil, handle.unavailable()
+	}
+
 	req, ch := newRequest("detach")
-	handle.send(req, ch)
+	id, err := handle.write(req, ch)
+	if err != nil {
+		abort(&handle.state)
+		return nil, err
+	}
+	defer handle.session.gateway.forget(id)
 
+	msg, err := handle.session.gateway.wait(ctx, "detach", ch)
+	if err != nil {
+		abort(&handle.state)
+		return nil, err
+	}
 	var ack *AckMsg
-	msg := <-ch
 	switch msg := msg.(type) {
 	case *AckMsg:
 		ack = msg
+	case *SuccessMsg:
+		// Janus confirms with success rather than ack
+		ack = &AckMsg{}
 	case *ErrorMsg:
+		abort(&handle.state)
 		return nil, msg
+	default:
+		abort(&handle.state)
+		return nil, unexpected("detach")
 	}
 
-	// Remove this handle from the session
-	handle.session.Lock()
-	delete(handle.session.Handles, handle.ID)
-	handle.session.Unlock()
+	handle.release()
 
 	return ack, nil
 }
@@ -1,11 +1,25 @@
 package janus
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/timsolov/janus-go/janustest"
 )
 
 func Test_Connect(t *testing.T) {
-	client, err := Connect("ws://localhost:8188/")
+	server := janustest.NewServer()
+	defer server.Close()
+
+	client, err := Connect(server.URL)
 	if err != nil {
 		t.Fail()
 		return
@@ -25,3 +39,716 @@ func Test_Connect(t *testing.T) {
 	//t.Log(sess)
 	//t.Log("connect")
 }
+
+// newTestServer starts a WebSocket server speaking janus-protocol which
+// passes every accepted connection to serve.
+func newTestServer(t *testing.T, serve func(conn *websocket.Conn)) (string, func()) {
+	upgrader := websocket.Upgrader{Subprotocols: []string{"janus-protocol"}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		serve(conn)
+	}))
+	return "ws" + strings.TrimPrefix(srv.URL, "http"), srv.Close
+}
+
+func TestGateway_InfoContext_Timeout(t *testing.T) {
+	url, stop := newTestServer(t, func(conn *websocket.Conn) {
+		// read requests, never answer
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	})
+	defer stop()
+
+	gateway, err := Connect(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gateway.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err = gateway.InfoContext(ctx)
+	var timeoutErr *TimeoutError
+	if !errors.As(err, &timeoutErr) {
+		t.Fatalf("expected *TimeoutError, got %v", err)
+	}
+	if timeoutErr.Request != "info" {
+		t.Errorf("expected request 'info', got '%s'", timeoutErr.Request)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+
+	gateway.mu.Lock()
+	pending := len(gateway.transactions)
+	gateway.mu.Unlock()
+	if pending != 0 {
+		t.Errorf("expected no pending transactions, found %d", pending)
+	}
+}
+
+// serveJanus answers every request read from conn with the messages returned
+// by reply, copying the request transaction into each of them. Returning nil
+// closes the connection.
+func serveJanus(conn *websocket.Conn, reply func(req map[string]interface{}) []map[string]interface{}) {
+	for {
+		var req map[string]interface{}
+		if err := conn.ReadJSON(&req); err != nil {
+			return
+		}
+		msgs := reply(req)
+		if msgs == nil {
+			return
+		}
+		for _, msg := range msgs {
+			if _, ok := msg["transaction"]; !ok {
+				msg["transaction"] = req["transaction"]
+			}
+			if err := conn.WriteJSON(msg); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func success(id uint64) []map[string]interface{} {
+	return []map[string]interface{}{{"janus": "success", "data": map[string]interface{}{"id": id}}}
+}
+
+func TestGateway_ConnectionLost(t *testing.T) {
+	url, stop := newTestServer(t, func(conn *websocket.Conn) {
+		serveJanus(conn, func(req map[string]interface{}) []map[string]interface{} {
+			switch req["janus"] {
+			case "create":
+				return success(1)
+			case "attach":
+				return success(2)
+			}
+			return nil
+		})
+	})
+	defer stop()
+
+	gateway, err := Connect(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gateway.Close()
+
+	session, err := gateway.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	handle, err := session.Attach("janus.plugin.echotest")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = handle.Message(map[string]interface{}{"audio": true}, nil); !errors.Is(err, ErrConnectionClosed) {
+		t.Errorf("expected ErrConnectionClosed, got %v", err)
+	}
+
+	select {
+	case <-gateway.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Done not closed after connection loss")
+	}
+	if !errors.Is(gateway.Err(), ErrConnectionClosed) {
+		t.Errorf("expected Err to wrap ErrConnectionClosed, got %v", gateway.Err())
+	}
+	if _, err = session.KeepAlive(); !errors.Is(err, ErrConnectionClosed) {
+		t.Errorf("expected ErrConnectionClosed on new request, got %v", err)
+	}
+
+	for name, ch := range map[string]chan interface{}{"handle": handle.Events, "session": session.Events} {
+		select {
+		case _, ok := <-ch:
+			if ok {
+				t.Errorf("unexpected event on %s events", name)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("%s events not closed", name)
+		}
+	}
+}
+
+func TestGateway_Reconnect(t *testing.T) {
+	var connections, sessions uint64
+	url, stop := newTestServer(t, func(conn *websocket.Conn) {
+		reconnected := atomic.AddUint64(&connections, 1) > 1
+		serveJanus(conn, func(req map[string]interface{}) []map[string]interface{} {
+			switch req["janus"] {
+			case "create":
+				return success(atomic.AddUint64(&sessions, 1))
+			case "claim":
+				if req["session_id"].(float64) == 1 {
+					return success(1)
+				}
+				return []map[string]interface{}{{"janus": "error", "error": map[string]interface{}{"code": 458, "reason": "No such session"}}}
+			case "keepalive":
+				if reconnected {
+					return []map[string]interface{}{{"janus": "ack"}}
+				}
+			}
+			return nil
+		})
+	})
+	defer stop()
+
+	reconnected := make(chan struct{})
+	reclaimFailed := make(chan uint64, 2)
+	gateway, err := Connect(url, WithReconnect(ReconnectConfig{
+		MinBackoff:  10 * time.Millisecond,
+		MaxAttempts: 3,
+		OnReconnect: func() {
+			close(reconnected)
+		},
+		OnReclaimFailed: func(session *Session, err error) {
+			reclaimFailed <- session.ID
+		},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gateway.Close()
+
+	claimed, err := gateway.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = gateway.Create(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the first connection drops on keepalive
+	if _, err = claimed.KeepAlive(); !errors.Is(err, ErrConnectionClosed) {
+		t.Errorf("expected ErrConnectionClosed, got %v", err)
+	}
+
+	select {
+	case <-reconnected:
+	case <-time.After(time.Second):
+		t.Fatal("OnReconnect not called")
+	}
+	select {
+	case id := <-reclaimFailed:
+		if id != 2 {
+			t.Errorf("expected reclaim of session 2 to fail, got %d", id)
+		}
+	default:
+		t.Error("OnReclaimFailed not called")
+	}
+
+	_, kept := gateway.Session(1)
+	_, dropped := gateway.Session(2)
+	if !kept || dropped {
+		t.Errorf("expected only session 1 to be kept, session 1 kept: %v, session 2 kept: %v", kept, dropped)
+	}
+
+	if _, err = claimed.KeepAlive(); err != nil {
+		t.Errorf("keepalive after reconnect: %v", err)
+	}
+	select {
+	case <-gateway.Done():
+		t.Error("Done closed although reconnected")
+	default:
+	}
+}
+
+func TestGateway_KeepAlive(t *testing.T) {
+	var keepalives uint64
+	url, stop := newTestServer(t, func(conn *websocket.Conn) {
+		serveJanus(conn, func(req map[string]interface{}) []map[string]interface{} {
+			switch req["janus"] {
+			case "create":
+				return success(1)
+			case "keepalive":
+				if atomic.AddUint64(&keepalives, 1) == 1 {
+					return []map[string]interface{}{{"janus": "ack"}}
+				}
+				return []map[string]interface{}{{"janus": "error", "error": map[string]interface{}{"code": 458, "reason": "No such session"}}}
+			case "destroy":
+				return []map[string]interface{}{{"janus": "ack"}}
+			}
+			return nil
+		})
+	})
+	defer stop()
+
+	gateway, err := Connect(url, WithKeepAlive(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gateway.Close()
+
+	session, err := gateway.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case msg := <-session.Events:
+		keepAliveErr, ok := msg.(*KeepAliveError)
+		if !ok {
+			t.Fatalf("expected *KeepAliveError, got %#v", msg)
+		}
+		var errMsg *ErrorMsg
+		if !errors.As(keepAliveErr, &errMsg) || errMsg.Err.Code != 458 {
+			t.Errorf("expected error 458, got %v", keepAliveErr.Err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("keepalive failure not reported")
+	}
+
+	if _, err = session.Destroy(); err != nil {
+		t.Fatal(err)
+	}
+	// let a keepalive racing with Destroy settle
+	time.Sleep(20 * time.Millisecond)
+	sent := atomic.LoadUint64(&keepalives)
+	time.Sleep(50 * time.Millisecond)
+	if atomic.LoadUint64(&keepalives) != sent {
+		t.Error("keepalives sent after Destroy")
+	}
+}
+
+
+func TestGateway_KeepAliveInfoUnanswered(t *testing.T) {
+	var sessions uint64
+	url, stop := newTestServer(t, func(conn *websocket.Conn) {
+		serveJanus(conn, func(req map[string]interface{}) []map[string]interface{} {
+			switch req["janus"] {
+			case "create":
+				return success(atomic.AddUint64(&sessions, 1))
+			case "info":
+				// never answered
+				return []map[string]interface{}{}
+			}
+			return nil
+		})
+	})
+	defer stop()
+
+	gateway, err := Connect(url, WithKeepAlive(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gateway.Close()
+
+	// Looking up the session-timeout does not hold up Create
+	for i := 0; i < 2; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		start := time.Now()
+		_, err := gateway.CreateContext(ctx)
+		cancel()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if elapsed := time.Since(start); elapsed > 250*time.Millisecond {
+			t.Errorf("create took %v", elapsed)
+		}
+	}
+}
+func TestConnect_Options(t *testing.T) {
+	authorization := make(chan string, 1)
+	upgrader := websocket.Upgrader{Subprotocols: []string{"janus-protocol"}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization <- r.Header.Get("Authorization")
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		conn.ReadMessage()
+	}))
+	defer srv.Close()
+
+	header := http.Header{}
+	header.Set("Authorization", "Bearer secret")
+	gateway, err := Connect("ws"+strings.TrimPrefix(srv.URL, "http"),
+		WithHeader(header),
+		WithHandshakeTimeout(time.Second),
+		WithWriteTimeout(time.Second),
+		WithPingInterval(0),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gateway.Close()
+
+	if got := <-authorization; got != "Bearer secret" {
+		t.Errorf("expected Authorization header, got '%s'", got)
+	}
+	if websocket.DefaultDialer.Subprotocols != nil {
+		t.Error("websocket.DefaultDialer has been modified")
+	}
+}
+
+func TestErrorMsg_Is(t *testing.T) {
+	_, msg, err := ParseMessage([]byte(`{"janus":"error","transaction":"1","error":{"code":458,"reason":"No such session 1234"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	errMsg := msg.(*ErrorMsg)
+
+	if !errors.Is(errMsg, ErrSessionNotFound) {
+		t.Error("expected ErrSessionNotFound to match")
+	}
+	if errors.Is(errMsg, ErrHandleNotFound) {
+		t.Error("unexpected match of ErrHandleNotFound")
+	}
+	if !errors.Is(&KeepAliveError{Session: 1234, Err: errMsg}, ErrSessionNotFound) {
+		t.Error("expected wrapped error to match ErrSessionNotFound")
+	}
+}
+
+func TestGateway_RouteEvents(t *testing.T) {
+	event := func(typ string, handle uint64) map[string]interface{} {
+		msg := map[string]interface{}{"janus": typ, "transaction": "", "session_id": 1}
+		if handle != 0 {
+			msg["sender"] = handle
+		}
+		return msg
+	}
+	url, stop := newTestServer(t, func(conn *websocket.Conn) {
+		serveJanus(conn, func(req map[string]interface{}) []map[string]interface{} {
+			switch req["janus"] {
+			case "create":
+				return success(1)
+			case "attach":
+				return success(2)
+			case "message":
+				// plugindata carrying responses belong to the requester
+				return []map[string]interface{}{
+					{"janus": "success", "session_id": 1, "sender": 2, "plugindata": map[string]interface{}{
+						"plugin": "janus.plugin.echotest", "data": map[string]interface{}{"result": "ok"},
+					}},
+					event("webrtcup", 2),
+					event("media", 2),
+					event("hangup", 2),
+					event("detached", 2),
+				}
+			case "keepalive":
+				return []map[string]interface{}{{"janus": "ack"}, event("timeout", 0)}
+			}
+			return nil
+		})
+	})
+	defer stop()
+
+	gateway, err := Connect(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gateway.Close()
+
+	session, err := gateway.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	handle, err := session.Attach("janus.plugin.echotest")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	msg, err := handle.RequestContext(ctx, map[string]interface{}{"request": "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.PluginData.Data["result"] != "ok" {
+		t.Errorf("unexpected response %+v", msg)
+	}
+
+	var events []interface{}
+	for event := range handle.Events {
+		events = append(events, event)
+	}
+	if len(events) != 4 {
+		t.Fatalf("expected 4 events, got %d", len(events))
+	}
+	for i, ok := range []bool{
+		isType(events[0], (*WebRTCUpMsg)(nil)),
+		isType(events[1], (*MediaMsg)(nil)),
+		isType(events[2], (*HangupMsg)(nil)),
+		isType(events[3], (*DetachedMsg)(nil)),
+	} {
+		if !ok {
+			t.Errorf("unexpected event %d: %#v", i, events[i])
+		}
+	}
+	handles := len(session.HandlesSnapshot())
+	if handles != 0 {
+		t.Errorf("expected detached handle to be removed, found %d handles", handles)
+	}
+
+	if _, err := session.KeepAlive(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case event := <-session.Events:
+		if _, ok := event.(*TimeoutMsg); !ok {
+			t.Errorf("expected *TimeoutMsg, got %#v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no timeout event delivered")
+	}
+	if _, ok := <-session.Events; ok {
+		t.Error("expected session events to be closed")
+	}
+	sessions := len(gateway.SessionsSnapshot())
+	if sessions != 0 {
+		t.Errorf("expected timed out session to be removed, found %d sessions", sessions)
+	}
+}
+
+func isType(event interface{}, typ interface{}) bool {
+	return fmt.Sprintf("%T", event) == fmt.Sprintf("%T", typ)
+}
+
+func TestHandle_Callbacks(t *testing.T) {
+	event := func(typ string) map[string]interface{} {
+		return map[string]interface{}{"janus": typ, "transaction": "", "session_id": 1, "sender": 2}
+	}
+	url, stop := newTestServer(t, func(conn *websocket.Conn) {
+		serveJanus(conn, func(req map[string]interface{}) []map[string]interface{} {
+			switch req["janus"] {
+			case "create":
+				return success(1)
+			case "attach":
+				return success(2)
+			case "keepalive":
+				msgs := []map[string]interface{}{{"janus": "ack"}, event("webrtcup")}
+				// More events than the Events channel holds
+				for i := 0; i < 20; i++ {
+					msgs = append(msgs, event("media"))
+				}
+				return append(msgs, event("hangup"), event("detached"))
+			}
+			return nil
+		})
+	})
+	defer stop()
+
+	gateway, err := Connect(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gateway.Close()
+
+	session, err := gateway.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	handle, err := session.Attach("janus.plugin.echotest")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var calls []string
+	detached := make(chan struct{})
+	handle.OnWebRTCUp(func(*WebRTCUpMsg) { calls = append(calls, "webrtcup") })
+	handle.OnMedia(func(*MediaMsg) { calls = append(calls, "media") })
+	handle.OnHangup(func(*HangupMsg) { calls = append(calls, "hangup") })
+	handle.OnDetached(func(*DetachedMsg) {
+		calls = append(calls, "detached")
+		close(detached)
+	})
+
+	if _, err := session.KeepAlive(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-detached:
+	case <-time.After(time.Second):
+		t.Fatal("OnDetached not called")
+	}
+
+	if len(calls) != 23 || calls[0] != "webrtcup" || calls[21] != "hangup" || calls[22] != "detached" {
+		t.Errorf("unexpected callbacks %v", calls)
+	}
+}
+
+func TestGateway_Tokens(t *testing.T) {
+	type auth struct{ token, apisecret interface{} }
+	requests := make(chan auth, 10)
+	url, stop := newTestServer(t, func(conn *websocket.Conn) {
+		serveJanus(conn, func(req map[string]interface{}) []map[string]interface{} {
+			requests <- auth{req["token"], req["apisecret"]}
+			switch req["janus"] {
+			case "create":
+				return success(1)
+			case "attach":
+				return success(2)
+			case "info":
+				return []map[string]interface{}{{"janus": "server_info"}}
+			}
+			return []map[string]interface{}{{"janus": "ack"}}
+		})
+	})
+	defer stop()
+
+	gateway, err := Connect(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gateway.Close()
+	gateway.Token = "default"
+	gateway.APISecret = "secret"
+
+	session, err := gateway.CreateWithToken("tenant")
+	if err != nil {
+		t.Fatal(err)
+	}
+	handle, err := session.Attach("janus.plugin.echotest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	handle.Token = "handle"
+	if _, err := handle.Trickle(map[string]interface{}{"completed": true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gateway.Info(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, expected := range []string{"tenant", "tenant", "handle", "default"} {
+		got := <-requests
+		if got.token != expected || got.apisecret != "secret" {
+			t.Errorf("request %d: expected token %s and apisecret, got %v", i, expected, got)
+		}
+	}
+}
+
+func TestGateway_Shutdown(t *testing.T) {
+	requests := make(chan string, 10)
+	url, stop := newTestServer(t, func(conn *websocket.Conn) {
+		serveJanus(conn, func(req map[string]interface{}) []map[string]interface{} {
+			requests <- req["janus"].(string)
+			switch req["janus"] {
+			case "create":
+				return success(1)
+			case "attach":
+				return success(2)
+			}
+			return []map[string]interface{}{{"janus": "ack"}}
+		})
+	})
+	defer stop()
+
+	gateway, err := Connect(url, WithPingInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, err := gateway.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	handle, err := session.Attach("janus.plugin.echotest")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := gateway.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := gateway.Shutdown(ctx); err != nil {
+		t.Errorf("expected second Shutdown to succeed, got %v", err)
+	}
+
+	for _, expected := range []string{"create", "attach", "detach", "destroy"} {
+		if got := <-requests; got != expected {
+			t.Errorf("expected %s request, got %s", expected, got)
+		}
+	}
+	select {
+	case <-gateway.Done():
+	default:
+		t.Error("Done not closed after Shutdown")
+	}
+	for name, ch := range map[string]chan interface{}{"handle": handle.Events, "session": session.Events} {
+		select {
+		case _, ok := <-ch:
+			if ok {
+				t.Errorf("unexpected event on %s events", name)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("%s events not closed", name)
+		}
+	}
+}
+
+func TestGateway_Janustest(t *testing.T) {
+	server := janustest.NewServer()
+	defer server.Close()
+
+	gateway, err := Connect(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gateway.Close()
+
+	session, err := gateway.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	handle, err := session.Attach(janustest.VideoroomPluginName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := handle.Request(map[string]interface{}{"request": "create", "description": "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	room := int(msg.PluginData.Data["room"].(float64))
+	if _, ok := server.Videoroom.Room(room); !ok {
+		t.Errorf("room %d not stored", room)
+	}
+
+	noError := func(err error) {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	noError(server.WebRTCUp(session.ID, handle.ID))
+	noError(server.PluginEvent(session.ID, handle.ID, map[string]interface{}{"videoroom": "event"}, nil))
+	noError(server.Hangup(session.ID, handle.ID, "DTLS alert"))
+
+	for _, expected := range []interface{}{(*WebRTCUpMsg)(nil), (*EventMsg)(nil), (*HangupMsg)(nil)} {
+		select {
+		case event := <-handle.Events:
+			if !isType(event, expected) {
+				t.Errorf("expected %T, got %#v", expected, event)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no %T delivered", expected)
+		}
+	}
+
+	noError(server.Timeout(session.ID))
+	select {
+	case event := <-session.Events:
+		if _, ok := event.(*TimeoutMsg); !ok {
+			t.Errorf("expected *TimeoutMsg, got %#v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no timeout event delivered")
+	}
+	if len(server.Sessions()) != 0 {
+		t.Errorf("expected no sessions left, got %v", server.Sessions())
+	}
+}
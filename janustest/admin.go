@@ -0,0 +1,341 @@
+package janustest
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+// serveAdmin answers admin API requests posted to /admin, /admin/<session>
+// and /admin/<session>/<handle>.
+func (s *Server) serveAdmin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Identifiers in the path take precedence over the payload
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin"), "/")
+	if path != "" {
+		for i, part := range strings.Split(path, "/") {
+			n, err := strconv.ParseUint(part, 10, 64)
+			if err != nil || i > 1 {
+				http.Error(w, "invalid path", http.StatusNotFound)
+				return
+			}
+			req[[]string{"session_id", "handle_id"}[i]] = float64(n)
+		}
+	}
+
+	msg := s.replyAdmin(req)
+	if _, ok := msg["transaction"]; !ok {
+		msg["transaction"] = req["transaction"]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(msg)
+}
+
+// serveAdminWs answers admin API requests sent over WebSocket with the
+// janus-admin-protocol subprotocol. Requests are answered concurrently, so
+// responses may arrive out of order.
+func (s *Server) serveAdminWs(w http.ResponseWriter, r *http.Request) {
+	upgrader := websocket.Upgrader{Subprotocols: []string{"janus-admin-protocol"}}
+	ws, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	c := &conn{ws: ws}
+
+	s.mu.Lock()
+	s.conns[c] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+		ws.Close()
+	}()
+
+	for {
+		var req Request
+		if err := ws.ReadJSON(&req); err != nil {
+			return
+		}
+		go func() {
+			msg := s.replyAdmin(req)
+			if _, ok := msg["transaction"]; !ok {
+				msg["transaction"] = req["transaction"]
+			}
+			c.write(msg)
+		}()
+	}
+}
+
+// replyAdmin returns the message answering an admin API request.
+func (s *Server) replyAdmin(req Request) Message {
+	s.mu.Lock()
+	s.adminRequests = append(s.adminRequests, req)
+	script := s.adminScripts[str(req["janus"])]
+	s.mu.Unlock()
+
+	if script != nil {
+		if msg := script(req); msg != nil {
+			return msg
+		}
+	}
+
+	// Like Janus, only info and ping are answered without the secret
+	request := str(req["janus"])
+	switch request {
+	case "info":
+		return serverInfo()
+	case "ping":
+		return Message{"janus": "pong"}
+	}
+
+	if s.adminSecret != "" && str(req["admin_secret"]) != s.adminSecret {
+		return errorMessage(codeUnauthorized, "Unauthorized request (wrong or missing secret/token)")
+	}
+
+	switch request {
+	case "message_plugin":
+		name := str(req["plugin"])
+		plugin, ok := s.plugins[name]
+		if !ok {
+			return errorMessage(codePluginNotFound, "No such plugin '%s'", name)
+		}
+		body, _ := req["request"].(map[string]interface{})
+		data, _ := plugin.HandleMessage(body)
+		return Message{"janus": "success", "response": data}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch request {
+	case "add_token", "allow_token", "disallow_token", "remove_token":
+		return s.token(request, req)
+	case "list_tokens":
+		tokens := make([]string, 0, len(s.tokens))
+		for token := range s.tokens {
+			tokens = append(tokens, token)
+		}
+		sort.Strings(tokens)
+		list := make([]map[string]interface{}, 0, len(tokens))
+		for _, token := range tokens {
+			list = append(list, map[string]interface{}{"token": token, "allowed_plugins": s.tokens[token]})
+		}
+		return Message{"janus": "success", "data": map[string]interface{}{"tokens": list}}
+	case "get_status":
+		status := copyMap(s.status)
+		status["admin_secret"] = s.adminSecret != ""
+		status["token_auth"] = len(s.tokens) > 0
+		return Message{"janus": "success", "status": status}
+	case "accept_new_sessions":
+		accept, ok := req["accept"].(bool)
+		if !ok {
+			return errorMessage(codeMissingElement, "Missing mandatory element (accept)")
+		}
+		s.refuse = !accept
+		return Message{"janus": "success", "accept": accept}
+	case "list_sessions":
+		ids := make([]uint64, 0, len(s.sessions))
+		for id := range s.sessions {
+			ids = append(ids, id)
+		}
+		return Message{"janus": "success", "sessions": sortIDs(ids)}
+	}
+
+	if setting, ok := settings[request]; ok {
+		return s.set(setting, req)
+	}
+
+	sessionID := id(req["session_id"])
+	sess, ok := s.sessions[sessionID]
+	if !ok {
+		return errorMessage(codeSessionNotFound, "No such session %d", sessionID)
+	}
+
+	switch request {
+	case "destroy_session":
+		delete(s.sessions, sessionID)
+		return Message{"janus": "success"}
+	case "list_handles":
+		ids := make([]uint64, 0, len(sess.handles))
+		for id := range sess.handles {
+			ids = append(ids, id)
+		}
+		return Message{"janus": "success", "session_id": sessionID, "handles": sortIDs(ids)}
+	}
+
+	handleID := id(req["handle_id"])
+	h, ok := sess.handles[handleID]
+	if !ok {
+		return errorMessage(codeHandleNotFound, "No such handle %d in session %d", handleID, sessionID)
+	}
+
+	// The client is notified once mu is released
+	switch request {
+	case "detach_handle":
+		delete(sess.handles, handleID)
+		go s.Notify(sessionID, Message{"janus": "detached", "sender": handleID})
+		return Message{"janus": "success"}
+	case "hangup_webrtc":
+		go s.Hangup(sessionID, handleID, "Janus API")
+		return Message{"janus": "success"}
+	case "start_pcap", "start_text2pcap":
+		if h.capture != "" {
+			return errorMessage(codeUnknown, "Handle is already dumping to %s", h.capture)
+		}
+		h.capture = strings.TrimPrefix(request, "start_")
+		h.captureFile = strings.TrimSuffix(str(req["folder"]), "/") + "/" + str(req["filename"])
+		return Message{"janus": "success"}
+	case "stop_pcap", "stop_text2pcap":
+		h.capture, h.captureFile = "", ""
+		return Message{"janus": "success"}
+	}
+
+	if request == "handle_info" {
+		info := map[string]interface{}{
+			"session_id": sessionID,
+			"handle_id":  handleID,
+			"plugin":     h.plugin,
+		}
+		if h.capture != "" {
+			info["dump-to-"+h.capture] = true
+			info["text2pcap-file"] = h.captureFile
+		}
+		return Message{
+			"janus":      "success",
+			"session_id": sessionID,
+			"handle_id":  handleID,
+			"info":       info,
+		}
+	}
+
+	return errorMessage(codeUnknownRequest, "Unknown request '%s'", request)
+}
+
+// setting describes a set_... request: the request element holding the new
+// value, the get_status element it changes and the element of the response.
+type setting struct {
+	element, status, response string
+	boolean                   bool
+}
+
+var settings = map[string]setting{
+	"set_session_timeout":    {"timeout", "session_timeout", "timeout", false},
+	"set_log_level":          {"level", "log_level", "level", false},
+	"set_log_timestamps":     {"timestamps", "log_timestamps", "log_timestamps", true},
+	"set_log_colors":         {"colors", "log_colors", "log_colors", true},
+	"set_locking_debug":      {"debug", "locking_debug", "locking_debug", true},
+	"set_refcount_debug":     {"debug", "refcount_debug", "refcount_debug", true},
+	"set_libnice_debug":      {"debug", "libnice_debug", "libnice_debug", true},
+	"set_min_nack_queue":     {"min_nack_queue", "min_nack_queue", "min_nack_queue", false},
+	"set_no_media_timer":     {"no_media_timer", "no_media_timer", "no_media_timer", false},
+	"set_slowlink_threshold": {"slowlink_threshold", "slowlink_threshold", "slowlink_threshold", false},
+}
+
+// set answers a set_... request. It is called with mu held.
+func (s *Server) set(setting setting, req Request) Message {
+	value, ok := req[setting.element]
+	if !ok {
+		return errorMessage(codeMissingElement, "Missing mandatory element (%s)", setting.element)
+	}
+	if _, isBool := value.(bool); isBool != setting.boolean {
+		return errorMessage(codeInvalidElementType, "Invalid element type (%s)", setting.element)
+	}
+	if !setting.boolean {
+		value = toInt(value)
+	}
+	s.status[setting.status] = value
+	return Message{"janus": "success", setting.response: value}
+}
+
+// defaultStatus returns the get_status settings of a new Server.
+func defaultStatus() map[string]interface{} {
+	return map[string]interface{}{
+		"api_secret":              false,
+		"session_timeout":         60,
+		"reclaim_session_timeout": 0,
+		"candidates_timeout":      45,
+		"log_level":               4,
+		"log_timestamps":          false,
+		"log_colors":              true,
+		"locking_debug":           false,
+		"refcount_debug":          false,
+		"libnice_debug":           false,
+		"min_nack_queue":          200,
+		"nack-optimizations":      false,
+		"no_media_timer":          1,
+		"slowlink_threshold":      0,
+	}
+}
+
+// token answers the token management requests. It is called with mu held.
+func (s *Server) token(request string, req Request) Message {
+	token := str(req["token"])
+	if token == "" {
+		return errorMessage(codeMissingElement, "Missing mandatory element (token)")
+	}
+	var plugins []string
+	if list, ok := req["plugins"].([]interface{}); ok {
+		for _, plugin := range list {
+			plugins = append(plugins, str(plugin))
+		}
+	}
+
+	allowed, ok := s.tokens[token]
+	if !ok && request != "add_token" {
+		return errorMessage(codeTokenNotFound, "Token %s not found", token)
+	}
+
+	switch request {
+	case "add_token":
+		allowed = plugins
+	case "allow_token":
+		for _, plugin := range plugins {
+			if !contains(allowed, plugin) {
+				allowed = append(allowed, plugin)
+			}
+		}
+	case "disallow_token":
+		kept := allowed[:0:0]
+		for _, plugin := range allowed {
+			if !contains(plugins, plugin) {
+				kept = append(kept, plugin)
+			}
+		}
+		allowed = kept
+	case "remove_token":
+		delete(s.tokens, token)
+		return Message{"janus": "success"}
+	}
+
+	if allowed == nil {
+		allowed = []string{}
+	}
+	s.tokens[token] = allowed
+	return Message{"janus": "success", "data": map[string]interface{}{"plugins": allowed}}
+}
+
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,282 @@
+package janustest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Names of the plugins emulated by default.
+const (
+	VideoroomPluginName = "janus.plugin.videoroom"
+	TextroomPluginName  = "janus.plugin.textroom"
+)
+
+// Plugin emulates a Janus plugin.
+type Plugin interface {
+	// HandleMessage answers the body of a message request, or the request
+	// of a message_plugin admin request. data is sent as the plugin data of
+	// a success response, or of an event following an ack if async is true.
+	HandleMessage(body map[string]interface{}) (data map[string]interface{}, async bool)
+}
+
+// PluginFunc adapts a function to the Plugin interface.
+type PluginFunc func(body map[string]interface{}) (data map[string]interface{}, async bool)
+
+// HandleMessage calls f(body).
+func (f PluginFunc) HandleMessage(body map[string]interface{}) (map[string]interface{}, bool) {
+	return f(body)
+}
+
+// roomPlugin holds what differs between the room management requests of the
+// VideoRoom and TextRoom plugins.
+type roomPlugin struct {
+	name                       string
+	created, edited, destroyed string
+	errorEvent                 string
+
+	codeInvalidRequest int
+	codeNoSuchRoom     int
+	codeRoomExists     int
+	codeUnauthorized   int
+}
+
+var videoroomPlugin = roomPlugin{
+	name:               "videoroom",
+	created:            "created",
+	edited:             "edited",
+	destroyed:          "destroyed",
+	errorEvent:         "event",
+	codeInvalidRequest: 423,
+	codeNoSuchRoom:     426,
+	codeRoomExists:     427,
+	codeUnauthorized:   433,
+}
+
+var textroomPlugin = roomPlugin{
+	name:               "textroom",
+	created:            "success",
+	edited:             "success",
+	destroyed:          "success",
+	errorEvent:         "error",
+	codeInvalidRequest: 415,
+	codeNoSuchRoom:     417,
+	codeRoomExists:     418,
+	codeUnauthorized:   419,
+}
+
+// RoomStore emulates the room management requests (create, edit, destroy,
+// exists and list) of the VideoRoom or TextRoom plugin. Rooms are kept as
+// the properties they were created with.
+type RoomStore struct {
+	// AdminKey, if set, is required to create rooms.
+	AdminKey string
+
+	plugin roomPlugin
+
+	mu       sync.Mutex
+	rooms    map[int]map[string]interface{}
+	nextRoom int
+}
+
+// NewVideoroomStore returns an empty RoomStore of the VideoRoom plugin.
+func NewVideoroomStore(adminKey string) *RoomStore {
+	return newRoomStore(videoroomPlugin, adminKey)
+}
+
+// NewTextroomStore returns an empty RoomStore of the TextRoom plugin.
+func NewTextroomStore(adminKey string) *RoomStore {
+	return newRoomStore(textroomPlugin, adminKey)
+}
+
+func newRoomStore(plugin roomPlugin, adminKey string) *RoomStore {
+	return &RoomStore{
+		AdminKey: adminKey,
+		plugin:   plugin,
+		rooms:    make(map[int]map[string]interface{}),
+		nextRoom: 1000,
+	}
+}
+
+// AddRoom stores a room with the given properties, assigning it an ID
+// unless room has one, and returns the ID.
+func (rs *RoomStore) AddRoom(room map[string]interface{}) int {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	return rs.add(copyMap(room))
+}
+
+// Room returns a copy of the properties of a room.
+func (rs *RoomStore) Room(id int) (map[string]interface{}, bool) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	room, ok := rs.rooms[id]
+	if !ok {
+		return nil, false
+	}
+	return copyMap(room), true
+}
+
+// Rooms returns the IDs of all rooms.
+func (rs *RoomStore) Rooms() []int {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	return rs.ids()
+}
+
+// HandleMessage answers the room management requests.
+func (rs *RoomStore) HandleMessage(body map[string]interface{}) (map[string]interface{}, bool) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	request := str(body["request"])
+	var resp map[string]interface{}
+	switch request {
+	case "create":
+		resp = rs.create(body)
+	case "edit":
+		resp = rs.edit(body)
+	case "destroy":
+		resp = rs.destroy(body)
+	case "exists":
+		_, exists := rs.rooms[toInt(body["room"])]
+		resp = rs.response("success", toInt(body["room"]))
+		resp["exists"] = exists
+	case "list":
+		resp = map[string]interface{}{rs.plugin.name: "success", "list": rs.list()}
+	default:
+		resp = rs.error(rs.plugin.codeInvalidRequest, "Unknown request '%s'", request)
+	}
+
+	if transaction, ok := body["transaction"]; ok {
+		resp["transaction"] = transaction
+	}
+	return resp, false
+}
+
+func (rs *RoomStore) create(body map[string]interface{}) map[string]interface{} {
+	if rs.AdminKey != "" && str(body["admin_key"]) != rs.AdminKey {
+		return rs.error(rs.plugin.codeUnauthorized, "Unauthorized (wrong admin_key)")
+	}
+	id := toInt(body["room"])
+	if _, ok := rs.rooms[id]; ok {
+		return rs.error(rs.plugin.codeRoomExists, "Room %d already exists", id)
+	}
+
+	room := copyMap(body)
+	for _, key := range []string{"request", "admin_key", "permanent", "allowed", "transaction"} {
+		delete(room, key)
+	}
+	resp := rs.response(rs.plugin.created, rs.add(room))
+	resp["permanent"] = body["permanent"] == true
+	return resp
+}
+
+func (rs *RoomStore) edit(body map[string]interface{}) map[string]interface{} {
+	id := toInt(body["room"])
+	room, errResp := rs.authorized(id, body)
+	if errResp != nil {
+		return errResp
+	}
+
+	for key, value := range body {
+		if strings.HasPrefix(key, "new_") {
+			room[strings.TrimPrefix(key, "new_")] = value
+		}
+	}
+	return rs.response(rs.plugin.edited, id)
+}
+
+func (rs *RoomStore) destroy(body map[string]interface{}) map[string]interface{} {
+	id := toInt(body["room"])
+	if _, errResp := rs.authorized(id, body); errResp != nil {
+		return errResp
+	}
+
+	delete(rs.rooms, id)
+	return rs.response(rs.plugin.destroyed, id)
+}
+
+// authorized returns the room with id if the secret of body matches its
+// secret, or the error response to send otherwise.
+func (rs *RoomStore) authorized(id int, body map[string]interface{}) (map[string]interface{}, map[string]interface{}) {
+	room, ok := rs.rooms[id]
+	if !ok {
+		return nil, rs.error(rs.plugin.codeNoSuchRoom, "No such room (%d)", id)
+	}
+	if secret := str(room["secret"]); secret != "" && str(body["secret"]) != secret {
+		return nil, rs.error(rs.plugin.codeUnauthorized, "Unauthorized (wrong secret)")
+	}
+	return room, nil
+}
+
+func (rs *RoomStore) list() []map[string]interface{} {
+	list := make([]map[string]interface{}, 0, len(rs.rooms))
+	for _, id := range rs.ids() {
+		entry := copyMap(rs.rooms[id])
+		entry["pin_required"] = str(entry["pin"]) != ""
+		entry["num_participants"] = 0
+		if rs.plugin.name == videoroomPlugin.name {
+			entry["max_publishers"] = entry["publishers"]
+		}
+		delete(entry, "secret")
+		delete(entry, "pin")
+		list = append(list, entry)
+	}
+	return list
+}
+
+// add stores room under its ID or a new one. It is called with mu held.
+func (rs *RoomStore) add(room map[string]interface{}) int {
+	id := toInt(room["room"])
+	for id == 0 {
+		rs.nextRoom++
+		if _, ok := rs.rooms[rs.nextRoom]; !ok {
+			id = rs.nextRoom
+		}
+	}
+	room["room"] = id
+	rs.rooms[id] = room
+	return id
+}
+
+func (rs *RoomStore) ids() []int {
+	ids := make([]int, 0, len(rs.rooms))
+	for id := range rs.rooms {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+func (rs *RoomStore) response(result string, id int) map[string]interface{} {
+	return map[string]interface{}{rs.plugin.name: result, "room": id}
+}
+
+func (rs *RoomStore) error(code int, format string, args ...interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		rs.plugin.name: rs.plugin.errorEvent,
+		"error_code":   code,
+		"error":        fmt.Sprintf(format, args...),
+	}
+}
+
+// toInt returns v as an int, JSON numbers are decoded to float64.
+func toInt(v interface{}) int {
+	switch v := v.(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	}
+	return 0
+}
+
+func copyMap(m map[string]interface{}) map[string]interface{} {
+	c := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
@@ -0,0 +1,557 @@
+// Package janustest provides an in-process emulation of the Janus WebRTC
+// Gateway for tests. A Server speaks the WebSocket client protocol and the
+// HTTP and WebSocket admin protocols, keeps fake sessions and handles,
+// answers requests to the VideoRoom and TextRoom plugins from in-memory room
+// stores, and lets tests script responses and inject events such as
+// webrtcup or hangup.
+//
+// The package only depends on the standard library and gorilla/websocket, so
+// it can be used by the tests of every package of this module.
+package janustest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// Request is a request received by the Server, as decoded from JSON.
+type Request map[string]interface{}
+
+// Message is a message sent by the Server, encoded to JSON.
+type Message map[string]interface{}
+
+// Janus error codes used by the Server.
+const (
+	codeUnauthorized       = 403
+	codeUnauthorizedPlugin = 405
+	codeMissingElement     = 456
+	codeInvalidElementType = 467
+	codeUnknownRequest     = 453
+	codeSessionNotFound    = 458
+	codeHandleNotFound     = 459
+	codePluginNotFound     = 460
+	codeTokenNotFound      = 470
+	codeNotAccepting       = 472
+	codeUnknown            = 490
+)
+
+// Server is an emulated Janus instance listening on a local port.
+type Server struct {
+	// URL is the WebSocket URL of the client API, e.g. ws://127.0.0.1:1234/
+	URL string
+
+	// AdminURL is the HTTP URL of the admin API, e.g.
+	// http://127.0.0.1:1234/admin
+	AdminURL string
+
+	// AdminWsURL is the WebSocket URL of the admin API, e.g.
+	// ws://127.0.0.1:1234/admin-ws
+	AdminWsURL string
+
+	// Videoroom and Textroom hold the rooms of the emulated plugins.
+	Videoroom *RoomStore
+	Textroom  *RoomStore
+
+	srv         *httptest.Server
+	adminSecret string
+	plugins     map[string]Plugin
+
+	mu            sync.Mutex
+	nextID        uint64
+	sessions      map[uint64]*session
+	conns         map[*conn]struct{}
+	tokens        map[string][]string
+	status        map[string]interface{}
+	refuse        bool
+	scripts       map[string]func(Request) []Message
+	adminScripts  map[string]func(Request) Message
+	requests      []Request
+	adminRequests []Request
+}
+
+type session struct {
+	id      uint64
+	conn    *conn
+	handles map[uint64]*handle
+}
+
+type handle struct {
+	id     uint64
+	plugin string
+
+	// capture is the format of the running packet capture, if any, and
+	// captureFile its path
+	capture     string
+	captureFile string
+}
+
+// conn is a WebSocket connection of a client.
+type conn struct {
+	ws *websocket.Conn
+	mu sync.Mutex
+}
+
+func (c *conn) write(msg Message) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ws.WriteJSON(msg)
+}
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithAdminSecret makes the admin API reject requests without the
+// admin_secret secret.
+func WithAdminSecret(secret string) Option {
+	return func(s *Server) {
+		s.adminSecret = secret
+	}
+}
+
+// WithPlugin registers the emulation of the plugin name, replacing the
+// default VideoRoom or TextRoom emulation for their names.
+func WithPlugin(name string, plugin Plugin) Option {
+	return func(s *Server) {
+		s.plugins[name] = plugin
+	}
+}
+
+// NewServer starts a Server. It must be closed with Close.
+func NewServer(opts ...Option) *Server {
+	s := &Server{
+		Videoroom:    NewVideoroomStore(""),
+		Textroom:     NewTextroomStore(""),
+		nextID:       1000,
+		sessions:     make(map[uint64]*session),
+		conns:        make(map[*conn]struct{}),
+		tokens:       make(map[string][]string),
+		status:       defaultStatus(),
+		scripts:      make(map[string]func(Request) []Message),
+		adminScripts: make(map[string]func(Request) Message),
+	}
+	s.plugins = map[string]Plugin{
+		VideoroomPluginName: s.Videoroom,
+		TextroomPluginName:  s.Textroom,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.serveClient)
+	mux.HandleFunc("/admin", s.serveAdmin)
+	mux.HandleFunc("/admin/", s.serveAdmin)
+	mux.HandleFunc("/admin-ws", s.serveAdminWs)
+	s.srv = httptest.NewServer(mux)
+
+	s.URL = "ws" + strings.TrimPrefix(s.srv.URL, "http") + "/"
+	s.AdminURL = s.srv.URL + "/admin"
+	s.AdminWsURL = s.URL + "admin-ws"
+	return s
+}
+
+// Close closes all connections and stops the Server.
+func (s *Server) Close() {
+	s.CloseConnections()
+	s.srv.Close()
+}
+
+// CloseConnections closes the WebSocket connections of all clients, while
+// keeping their sessions so that they can be claimed after reconnecting.
+func (s *Server) CloseConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.conns {
+		c.ws.Close()
+	}
+}
+
+// Handle scripts the responses to client API requests of type request, e.g.
+// "message". The messages returned by f are sent instead of the default
+// response, with the transaction of the request unless they have their own.
+// If f returns nil, the default response is sent.
+func (s *Server) Handle(request string, f func(Request) []Message) {
+	s.mu.Lock()
+	s.scripts[request] = f
+	s.mu.Unlock()
+}
+
+// HandleAdmin scripts the response to admin API requests of type request.
+// If f returns nil, the default response is sent.
+func (s *Server) HandleAdmin(request string, f func(Request) Message) {
+	s.mu.Lock()
+	s.adminScripts[request] = f
+	s.mu.Unlock()
+}
+
+// Requests returns the client API requests received so far.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// AdminRequests returns the admin API requests received so far.
+func (s *Server) AdminRequests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.adminRequests...)
+}
+
+// Sessions returns the IDs of the active sessions.
+func (s *Server) Sessions() []uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := make([]uint64, 0, len(s.sessions))
+	for id := range s.sessions {
+		ids = append(ids, id)
+	}
+	return sortIDs(ids)
+}
+
+// Handles returns the IDs of the handles of a session.
+func (s *Server) Handles(sessionID uint64) []uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[sessionID]
+	if !ok {
+		return nil
+	}
+	ids := make([]uint64, 0, len(sess.handles))
+	for id := range sess.handles {
+		ids = append(ids, id)
+	}
+	return sortIDs(ids)
+}
+
+// Notify sends msg to the client owning the session, setting its
+// session_id. Use it to inject events the helpers below do not cover.
+func (s *Server) Notify(sessionID uint64, msg Message) error {
+	s.mu.Lock()
+	var c *conn
+	sess, ok := s.sessions[sessionID]
+	if ok {
+		c = sess.conn
+	}
+	s.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("janustest: no such session %d", sessionID)
+	}
+	if c == nil {
+		return fmt.Errorf("janustest: session %d has no connection", sessionID)
+	}
+
+	msg["session_id"] = sessionID
+	return c.write(msg)
+}
+
+// WebRTCUp sends a webrtcup event for a handle.
+func (s *Server) WebRTCUp(sessionID, handleID uint64) error {
+	return s.Notify(sessionID, Message{"janus": "webrtcup", "sender": handleID})
+}
+
+// Media sends a media event for a handle.
+func (s *Server) Media(sessionID, handleID uint64, kind string, receiving bool) error {
+	return s.Notify(sessionID, Message{"janus": "media", "sender": handleID, "type": kind, "receiving": receiving})
+}
+
+// Hangup sends a hangup event for a handle.
+func (s *Server) Hangup(sessionID, handleID uint64, reason string) error {
+	return s.Notify(sessionID, Message{"janus": "hangup", "sender": handleID, "reason": reason})
+}
+
+// PluginEvent sends an event of the plugin of a handle carrying data, and
+// jsep unless it is nil.
+func (s *Server) PluginEvent(sessionID, handleID uint64, data, jsep map[string]interface{}) error {
+	s.mu.Lock()
+	h, err := s.lookup(sessionID, handleID)
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	msg := Message{
+		"janus":      "event",
+		"sender":     handleID,
+		"plugindata": map[string]interface{}{"plugin": h.plugin, "data": data},
+	}
+	if jsep != nil {
+		msg["jsep"] = jsep
+	}
+	return s.Notify(sessionID, msg)
+}
+
+// Detach removes a handle, as if its plugin had released it, and sends a
+// detached event.
+func (s *Server) Detach(sessionID, handleID uint64) error {
+	s.mu.Lock()
+	_, err := s.lookup(sessionID, handleID)
+	if err == nil {
+		delete(s.sessions[sessionID].handles, handleID)
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return s.Notify(sessionID, Message{"janus": "detached", "sender": handleID})
+}
+
+// Timeout removes a session, as if it had not been kept alive, and sends a
+// timeout event.
+func (s *Server) Timeout(sessionID uint64) error {
+	err := s.Notify(sessionID, Message{"janus": "timeout"})
+	s.mu.Lock()
+	delete(s.sessions, sessionID)
+	s.mu.Unlock()
+	return err
+}
+
+// lookup returns the handle of a session. It is called with mu held.
+func (s *Server) lookup(sessionID, handleID uint64) (*handle, error) {
+	sess, ok := s.sessions[sessionID]
+	if !ok {
+		return nil, fmt.Errorf("janustest: no such session %d", sessionID)
+	}
+	h, ok := sess.handles[handleID]
+	if !ok {
+		return nil, fmt.Errorf("janustest: no such handle %d in session %d", handleID, sessionID)
+	}
+	return h, nil
+}
+
+func (s *Server) serveClient(w http.ResponseWriter, r *http.Request) {
+	upgrader := websocket.Upgrader{Subprotocols: []string{"janus-protocol"}}
+	ws, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	c := &conn{ws: ws}
+
+	s.mu.Lock()
+	s.conns[c] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		for _, sess := range s.sessions {
+			if sess.conn == c {
+				sess.conn = nil
+			}
+		}
+		s.mu.Unlock()
+		ws.Close()
+	}()
+
+	for {
+		var req Request
+		if err := ws.ReadJSON(&req); err != nil {
+			return
+		}
+		for _, msg := range s.reply(c, req) {
+			if _, ok := msg["transaction"]; !ok {
+				msg["transaction"] = req["transaction"]
+			}
+			if err := c.write(msg); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// reply returns the messages answering a client API request.
+func (s *Server) reply(c *conn, req Request) []Message {
+	s.mu.Lock()
+	s.requests = append(s.requests, req)
+	script := s.scripts[str(req["janus"])]
+	s.mu.Unlock()
+
+	if script != nil {
+		if msgs := script(req); msgs != nil {
+			return msgs
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	request := str(req["janus"])
+	switch request {
+	case "info":
+		return []Message{serverInfo()}
+	case "ping":
+		return []Message{{"janus": "pong"}}
+	}
+
+	allowed, denied := s.authorize(req)
+	if denied != nil {
+		return []Message{denied}
+	}
+
+	if request == "create" {
+		if s.refuse {
+			return []Message{errorMessage(codeNotAccepting, "Currently not accepting new sessions")}
+		}
+		s.nextID++
+		sess := &session{id: s.nextID, conn: c, handles: make(map[uint64]*handle)}
+		s.sessions[sess.id] = sess
+		return []Message{{"janus": "success", "data": map[string]interface{}{"id": sess.id}}}
+	}
+
+	sessionID := id(req["session_id"])
+	sess, ok := s.sessions[sessionID]
+	if !ok {
+		return []Message{errorMessage(codeSessionNotFound, "No such session %d", sessionID)}
+	}
+
+	switch request {
+	case "keepalive":
+		return []Message{{"janus": "ack", "session_id": sessionID}}
+	case "claim":
+		sess.conn = c
+		return []Message{{"janus": "success", "session_id": sessionID}}
+	case "destroy":
+		delete(s.sessions, sessionID)
+		return []Message{{"janus": "success", "session_id": sessionID}}
+	case "attach":
+		plugin := str(req["plugin"])
+		if plugin == "" {
+			return []Message{errorMessage(codeMissingElement, "Missing mandatory element (plugin)")}
+		}
+		if !allows(allowed, plugin) {
+			return []Message{errorMessage(codeUnauthorizedPlugin, "Provided token can't access plugin %s", plugin)}
+		}
+		s.nextID++
+		sess.handles[s.nextID] = &handle{id: s.nextID, plugin: plugin}
+		return []Message{{"janus": "success", "session_id": sessionID, "data": map[string]interface{}{"id": s.nextID}}}
+	}
+
+	handleID := id(req["handle_id"])
+	h, ok := sess.handles[handleID]
+	if !ok {
+		return []Message{errorMessage(codeHandleNotFound, "No such handle %d in session %d", handleID, sessionID)}
+	}
+
+	switch request {
+	case "detach":
+		delete(sess.handles, handleID)
+		return []Message{
+			{"janus": "success", "session_id": sessionID, "sender": handleID},
+			{"janus": "detached", "session_id": sessionID, "sender": handleID, "transaction": nil},
+		}
+	case "hangup":
+		return []Message{{"janus": "success", "session_id": sessionID, "sender": handleID}}
+	case "trickle":
+		return []Message{{"janus": "ack", "session_id": sessionID}}
+	case "message":
+		return s.message(sessionID, h, req)
+	}
+
+	return []Message{errorMessage(codeUnknownRequest, "Unknown request '%s'", request)}
+}
+
+// message passes the body of a message request to the plugin of a handle.
+// Plugins without an emulation acknowledge the request and send an event
+// with result ok, like the EchoTest plugin.
+func (s *Server) message(sessionID uint64, h *handle, req Request) []Message {
+	pluginData := func(data map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{"plugin": h.plugin, "data": data}
+	}
+
+	plugin, ok := s.plugins[h.plugin]
+	if !ok {
+		return []Message{
+			{"janus": "ack", "session_id": sessionID},
+			{"janus": "event", "session_id": sessionID, "sender": h.id,
+				"plugindata": pluginData(map[string]interface{}{"result": "ok"})},
+		}
+	}
+
+	body, _ := req["body"].(map[string]interface{})
+	data, async := plugin.HandleMessage(body)
+	if async {
+		return []Message{
+			{"janus": "ack", "session_id": sessionID},
+			{"janus": "event", "session_id": sessionID, "sender": h.id, "plugindata": pluginData(data)},
+		}
+	}
+	return []Message{{"janus": "success", "session_id": sessionID, "sender": h.id, "plugindata": pluginData(data)}}
+}
+
+// authorize checks the token of a request once tokens have been added
+// through the admin API, and returns the plugins the token may access. It
+// is called with mu held.
+func (s *Server) authorize(req Request) ([]string, Message) {
+	if len(s.tokens) == 0 {
+		return nil, nil
+	}
+	plugins, ok := s.tokens[str(req["token"])]
+	if !ok {
+		return nil, errorMessage(codeUnauthorized, "Unauthorized request (wrong or missing secret/token)")
+	}
+	return plugins, nil
+}
+
+// allows reports whether plugin is in allowed, an empty list allows all.
+func allows(allowed []string, plugin string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, p := range allowed {
+		if p == plugin {
+			return true
+		}
+	}
+	return false
+}
+
+func serverInfo() Message {
+	return Message{
+		"janus":                   "server_info",
+		"name":                    "Janus WebRTC Server",
+		"version":                 1000,
+		"version_string":          "1.0.0",
+		"author":                  "janustest",
+		"data_channels":           true,
+		"session-timeout":         60,
+		"reclaim-session-timeout": 0,
+		"transports": map[string]interface{}{
+			"janus.transport.websockets": map[string]interface{}{"name": "JANUS WebSockets transport"},
+			"janus.transport.http":       map[string]interface{}{"name": "JANUS REST (HTTP/HTTPS) transport"},
+		},
+		"plugins": map[string]interface{}{
+			VideoroomPluginName: map[string]interface{}{"name": "JANUS VideoRoom plugin"},
+			TextroomPluginName:  map[string]interface{}{"name": "JANUS TextRoom plugin"},
+		},
+	}
+}
+
+func errorMessage(code int, format string, args ...interface{}) Message {
+	return Message{
+		"janus": "error",
+		"error": map[string]interface{}{"code": code, "reason": fmt.Sprintf(format, args...)},
+	}
+}
+
+// str returns v if it is a string.
+func str(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
+
+// id returns v as an ID, JSON numbers are decoded to float64.
+func id(v interface{}) uint64 {
+	n, _ := v.(float64)
+	return uint64(n)
+}
+
+func sortIDs(ids []uint64) []uint64 {
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
@@ -0,0 +1,88 @@
+package janus
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// defaultKeepAliveInterval is used when the server does not report its
+// session-timeout.
+const defaultKeepAliveInterval = 30 * time.Second
+
+// keepAliveInfoTimeout bounds the info request looking up the session-timeout.
+const keepAliveInfoTimeout = 10 * time.Second
+
+// KeepAliveError is delivered on Session.Events when a keepalive request sent
+// by the loop started through WithKeepAlive fails.
+type KeepAliveError struct {
+	Session uint64
+	Err     error
+}
+
+func (err *KeepAliveError) Error() string {
+	return fmt.Sprintf("keepalive for session %d: %s", err.Session, err.Err)
+}
+
+func (err *KeepAliveError) Unwrap() error {
+	return err.Err
+}
+
+// sessionKeepAlive returns the interval for session keepalive loops. Unless
+// configured through WithKeepAlive, the interval is half the session-timeout
+// of the server, which is requested once and cached. If the request fails,
+// defaultKeepAliveInterval is cached instead.
+func (gateway *Gateway) sessionKeepAlive() time.Duration {
+	if gateway.options.keepAliveInterval > 0 {
+		return gateway.options.keepAliveInterval
+	}
+
+	gateway.keepAliveMu.Lock()
+	defer gateway.keepAliveMu.Unlock()
+	if gateway.keepAliveInterval > 0 {
+		return gateway.keepAliveInterval
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), keepAliveInfoTimeout)
+	defer cancel()
+	info, err := gateway.InfoContext(ctx)
+	gateway.keepAliveInterval = defaultKeepAliveInterval
+	if err != nil {
+		gateway.log().Warn("unable to get session-timeout for keepalive", "error", err)
+		return gateway.keepAliveInterval
+	}
+	if info.SessionTimeout > 0 {
+		gateway.keepAliveInterval = time.Duration(info.SessionTimeout) * time.Second / 2
+	}
+	return gateway.keepAliveInterval
+}
+
+// keepAlive sends a keepalive request every interval, see sessionKeepAlive,
+// until the session is destroyed or the connection to the Gateway is lost.
+func (session *Session) keepAlive() {
+	interval := session.gateway.sessionKeepAlive()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			_, err := session.KeepAliveContext(ctx)
+			cancel()
+			if err != nil {
+				session.events.push(&KeepAliveError{Session: session.ID, Err: err})
+			}
+		case <-session.stop:
+			return
+		case <-session.gateway.done:
+			return
+		}
+	}
+}
+
+func (session *Session) stopKeepAlive() {
+	session.stopOnce.Do(func() {
+		close(session.stop)
+	})
+}
@@ -0,0 +1,75 @@
+package janus
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Logger receives the diagnostic messages of a Gateway, its Transport and
+// the admin API client. keyvals are alternating keys and values adding
+// context to the message, e.g. "session_id", 1234, "transaction", "42".
+type Logger interface {
+	Debug(msg string, keyvals ...interface{})
+	Info(msg string, keyvals ...interface{})
+	Warn(msg string, keyvals ...interface{})
+	Error(msg string, keyvals ...interface{})
+}
+
+// NopLogger discards all messages, it is the default Logger.
+type NopLogger struct{}
+
+func (NopLogger) Debug(msg string, keyvals ...interface{}) {}
+func (NopLogger) Info(msg string, keyvals ...interface{})  {}
+func (NopLogger) Warn(msg string, keyvals ...interface{})  {}
+func (NopLogger) Error(msg string, keyvals ...interface{}) {}
+
+// StdLogger writes messages to a standard library *log.Logger as
+// "LEVEL msg key=value ...". Debug messages are dropped unless Verbose is
+// set.
+type StdLogger struct {
+	Logger  *log.Logger
+	Verbose bool
+}
+
+// NewStdLogger returns a StdLogger writing to l, or to the standard logger of
+// the log package if l is nil.
+func NewStdLogger(l *log.Logger, verbose bool) *StdLogger {
+	if l == nil {
+		l = log.New(log.Writer(), log.Prefix(), log.Flags())
+	}
+	return &StdLogger{Logger: l, Verbose: verbose}
+}
+
+func (l *StdLogger) Debug(msg string, keyvals ...interface{}) {
+	if l.Verbose {
+		l.print("DEBUG", msg, keyvals)
+	}
+}
+
+func (l *StdLogger) Info(msg string, keyvals ...interface{}) {
+	l.print("INFO", msg, keyvals)
+}
+
+func (l *StdLogger) Warn(msg string, keyvals ...interface{}) {
+	l.print("WARN", msg, keyvals)
+}
+
+func (l *StdLogger) Error(msg string, keyvals ...interface{}) {
+	l.print("ERROR", msg, keyvals)
+}
+
+func (l *StdLogger) print(level, msg string, keyvals []interface{}) {
+	var sb strings.Builder
+	sb.WriteString(level)
+	sb.WriteByte(' ')
+	sb.WriteString(msg)
+	for i := 0; i < len(keyvals); i += 2 {
+		if i+1 < len(keyvals) {
+			fmt.Fprintf(&sb, " %v=%v", keyvals[i], keyvals[i+1])
+		} else {
+			fmt.Fprintf(&sb, " %v=?", keyvals[i])
+		}
+	}
+	l.Logger.Println(sb.String())
+}
@@ -0,0 +1,47 @@
+package janus
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStdLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewStdLogger(log.New(&buf, "", 0), false)
+
+	logger.Debug("hidden")
+	logger.Warn("unable to deliver message", "session_id", 1, "handle_id")
+	if got := buf.String(); got != "WARN unable to deliver message session_id=1 handle_id=?\n" {
+		t.Errorf("unexpected output %q", got)
+	}
+}
+
+func TestGateway_Logger(t *testing.T) {
+	var buf bytes.Buffer
+	client, server := NewPipeTransport()
+	gateway, err := ConnectTransport(func() (Transport, error) {
+		return client, nil
+	}, WithLogger(NewStdLogger(log.New(&buf, "", 0), true)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server.Send([]byte(`{"janus":"bogus"}`))
+	server.Close()
+	select {
+	case <-gateway.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Done not closed")
+	}
+
+	out := buf.String()
+	if !strings.Contains(out, "WARN unable to parse message error=unknown message type received: bogus") {
+		t.Errorf("parse error not logged: %q", out)
+	}
+	if !strings.Contains(out, "INFO connection closed") {
+		t.Errorf("disconnect not logged: %q", out)
+	}
+}
@@ -0,0 +1,129 @@
+package janus
+
+import (
+	"context"
+	"strconv"
+	"sync"
+)
+
+// PendingMessage is a message sent to the plugin of a Handle with
+// MessageAsync. It receives the events the plugin tags with the transaction
+// of the message, until it is closed.
+type PendingMessage struct {
+	// Transaction is the transaction identifier of the message.
+	Transaction string
+
+	handle *Handle
+	id     uint64
+	ch     chan interface{}
+
+	// done is closed with err once the message is closed or its handle is
+	// gone
+	done     chan struct{}
+	doneOnce sync.Once
+	err      error
+}
+
+// MessageAsync sends a message request to the plugin of this handle without
+// waiting for its response. The returned PendingMessage receives the events
+// correlated to the request, such as the answer of a videoroom configure
+// request carrying a JSEP, one at a time through Next. Close must be called
+// once no more events are expected, afterwards correlated events are passed
+// to the Events channel of the handle like any other event. So are the
+// correlated events which do not fit in a buffer of the event queue size set
+// by WithEventQueue while Next is not called.
+func (handle *Handle) MessageAsync(body, jsep interface{}) (*PendingMessage, error) {
+	req := map[string]interface{}{"janus": "message"}
+	if body != nil {
+		req["body"] = body
+	}
+	if jsep != nil {
+		req["jsep"] = jsep
+	}
+
+	// Correlated events not read yet are queued like the events of a handle
+	ch := make(chan interface{}, handle.session.gateway.options.eventQueueSize)
+	id, err := handle.send(req, ch)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := &PendingMessage{
+		Transaction: strconv.FormatUint(id, 10),
+		handle:      handle,
+		id:          id,
+		ch:          ch,
+		done:        make(chan struct{}),
+	}
+	handle.session.mu.Lock()
+	if handle.pending == nil {
+		handle.pending = make(map[uint64]*PendingMessage)
+	}
+	handle.pending[id] = pending
+	handle.session.mu.Unlock()
+	// The handle may be gone meanwhile
+	if handle.State() == StateGone {
+		handle.failPending()
+	}
+
+	return pending, nil
+}
+
+// Next waits for the next event correlated to the message, skipping the ack
+// of the Gateway. An error response of the Gateway is returned as an
+// *ErrorMsg, and a *TimeoutError is returned when ctx is done first. Events
+// arriving after a timeout are still returned by the next call to Next.
+// Once the events received before are read, Next fails with ErrMessageClosed
+// after Close, or ErrHandleDetached once the handle is gone.
+func (pending *PendingMessage) Next(ctx context.Context) (*EventMsg, error) {
+	gateway := pending.handle.session.gateway
+	for {
+		var msg interface{}
+		select {
+		case msg = <-pending.ch:
+		default:
+			select {
+			case msg = <-pending.ch:
+			case <-pending.done:
+				return nil, pending.err
+			case <-ctx.Done():
+				return nil, &TimeoutError{Request: "message", Err: ctx.Err()}
+			case <-gateway.done:
+				return nil, gateway.Err()
+			}
+		}
+		switch msg := msg.(type) {
+		case failure:
+			return nil, msg.err
+		case *AckMsg:
+			continue
+		case *EventMsg:
+			return msg, nil
+		case *ErrorMsg:
+			return nil, msg
+		}
+
+		return nil, unexpected("message")
+	}
+}
+
+// Close stops correlating events to the message. It is safe to call Close
+// more than once. Messages of a handle that is detached, or whose session is
+// gone, are closed and Next fails with ErrHandleDetached.
+func (pending *PendingMessage) Close() {
+	handle := pending.handle
+	handle.session.mu.Lock()
+	delete(handle.pending, pending.id)
+	handle.session.mu.Unlock()
+	handle.session.gateway.forget(pending.id)
+	pending.finish(ErrMessageClosed)
+}
+
+// finish makes Next fail with err once the events received are read. Only
+// the first call has an effect.
+func (pending *PendingMessage) finish(err error) {
+	pending.doneOnce.Do(func() {
+		pending.err = err
+		close(pending.done)
+	})
+}
@@ -0,0 +1,178 @@
+package janus
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/timsolov/janus-go/janustest"
+)
+
+func TestHandle_MessageAsync(t *testing.T) {
+	url, stop := newTestServer(t, func(conn *websocket.Conn) {
+		serveJanus(conn, func(req map[string]interface{}) []map[string]interface{} {
+			switch req["janus"] {
+			case "create":
+				return success(1)
+			case "attach":
+				return success(2)
+			case "message":
+				plugindata := map[string]interface{}{"plugin": "janus.plugin.videoroom", "data": map[string]interface{}{"configured": "ok"}}
+				return []map[string]interface{}{
+					{"janus": "ack"},
+					{"janus": "event", "transaction": "", "session_id": 1, "sender": 2, "plugindata": plugindata},
+					{"janus": "event", "session_id": 1, "sender": 2, "plugindata": plugindata},
+					{"janus": "event", "session_id": 1, "sender": 2, "plugindata": plugindata,
+						"jsep": map[string]interface{}{"type": "answer", "sdp": "v=0"}},
+				}
+			}
+			return nil
+		})
+	})
+	defer stop()
+
+	gateway, err := Connect(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gateway.Close()
+
+	session, err := gateway.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	handle, err := session.Attach("janus.plugin.videoroom")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pending, err := handle.MessageAsync(map[string]interface{}{"request": "configure"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pending.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	first, err := pending.Next(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Plugindata.Data["configured"] != "ok" || first.Jsep != nil {
+		t.Errorf("unexpected first event %+v", first)
+	}
+	second, err := pending.Next(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.Jsep["type"] != "answer" {
+		t.Errorf("expected JSEP answer, got %+v", second)
+	}
+
+	// The uncorrelated event went to the handle
+	select {
+	case event := <-handle.Events:
+		if _, ok := event.(*EventMsg); !ok {
+			t.Errorf("expected *EventMsg, got %#v", event)
+		}
+	case <-time.After(time.Second):
+		t.Error("uncorrelated event not delivered to the handle")
+	}
+
+	short, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	var timeoutErr *TimeoutError
+	if _, err := pending.Next(short); !errors.As(err, &timeoutErr) {
+		t.Errorf("expected *TimeoutError, got %v", err)
+	}
+}
+
+func TestGateway_ShutdownPendingMessage(t *testing.T) {
+	server := janustest.NewServer()
+	defer server.Close()
+
+	gateway, err := Connect(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, err := gateway.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	handle, err := session.Attach("janus.plugin.echotest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pending, err := handle.MessageAsync(map[string]interface{}{"audio": true}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	if err := gateway.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Shutdown waited %s for the open PendingMessage", elapsed)
+	}
+
+	// The events may have been received before the detach
+	for {
+		_, err := pending.Next(ctx)
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, ErrHandleDetached) && !errors.Is(err, ErrConnectionClosed) {
+			t.Errorf("expected ErrHandleDetached, got %v", err)
+		}
+		break
+	}
+}
+
+func TestPendingMessage_NextAfterClose(t *testing.T) {
+	server := janustest.NewServer()
+	defer server.Close()
+
+	// The unread ack fills the buffer of the message
+	gateway, err := Connect(server.URL, WithEventQueue(1, OverflowDropOldest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gateway.Close()
+	session, err := gateway.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	handle, err := session.Attach("janus.plugin.echotest")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	closed, err := handle.MessageAsync(map[string]interface{}{"audio": true}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	detached, err := handle.MessageAsync(map[string]interface{}{"audio": true}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	closed.Close()
+	if _, err := handle.Detach(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := closed.Next(ctx); !errors.Is(err, ErrMessageClosed) {
+		t.Errorf("expected ErrMessageClosed, got %v", err)
+	}
+	if _, err := detached.Next(ctx); !errors.Is(err, ErrHandleDetached) {
+		t.Errorf("expected ErrHandleDetached, got %v", err)
+	}
+}
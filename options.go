@@ -0,0 +1,282 @@
+package janus
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// defaultPingInterval is the interval of liveness checks on the transport.
+const defaultPingInterval = 30 * time.Second
+
+// defaultWriteTimeout bounds every write to the transport.
+const defaultWriteTimeout = 10 * time.Second
+
+// defaultSendQueueSize is the number of frames waiting to be written.
+const defaultSendQueueSize = 100
+
+// Option configures optional behaviour of a Gateway created by Connect, or
+// of a Transport created by one of the New...Transport functions.
+type Option func(*options)
+
+type options struct {
+	reconnect *ReconnectConfig
+
+	keepAlive         bool
+	keepAliveInterval time.Duration
+
+	dialer           *websocket.Dialer
+	tlsConfig        *tls.Config
+	header           http.Header
+	proxy            func(*http.Request) (*url.URL, error)
+	handshakeTimeout time.Duration
+	readLimit        int64
+	pingInterval     time.Duration
+	writeTimeout     time.Duration
+
+	logger Logger
+
+	eventQueueSize int
+	overflowPolicy OverflowPolicy
+
+	sendQueueSize int
+
+	recorder *Recorder
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		pingInterval:   defaultPingInterval,
+		writeTimeout:   defaultWriteTimeout,
+		logger:         NopLogger{},
+		eventQueueSize: defaultEventQueueSize,
+		overflowPolicy: OverflowDropOldest,
+		sendQueueSize:  defaultSendQueueSize,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// wsDialer returns a copy of the configured dialer, or of
+// websocket.DefaultDialer, with the connect options applied.
+func (o *options) wsDialer() *websocket.Dialer {
+	dialer := *websocket.DefaultDialer
+	if o.dialer != nil {
+		dialer = *o.dialer
+	}
+	dialer.Subprotocols = []string{"janus-protocol"}
+	if o.tlsConfig != nil {
+		dialer.TLSClientConfig = o.tlsConfig
+	}
+	if o.proxy != nil {
+		dialer.Proxy = o.proxy
+	}
+	if o.handshakeTimeout > 0 {
+		dialer.HandshakeTimeout = o.handshakeTimeout
+	}
+	return &dialer
+}
+
+// httpClient returns a client with the connect options applied. Janus
+// answers a long poll after 30 seconds without events, so the client
+// timeout must be well above that.
+func (o *options) httpClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if o.tlsConfig != nil {
+		transport.TLSClientConfig = o.tlsConfig
+	}
+	if o.proxy != nil {
+		transport.Proxy = o.proxy
+	}
+	if o.handshakeTimeout > 0 {
+		transport.TLSHandshakeTimeout = o.handshakeTimeout
+	}
+	return &http.Client{Transport: transport, Timeout: 60 * time.Second}
+}
+
+// ReconnectConfig configures the automatic reconnect mode enabled by
+// WithReconnect.
+type ReconnectConfig struct {
+	// MinBackoff is the delay before the first redial attempt, it doubles
+	// after every failed attempt. Defaults to one second.
+	MinBackoff time.Duration
+
+	// MaxBackoff caps the delay between redial attempts. Defaults to 30
+	// seconds.
+	MaxBackoff time.Duration
+
+	// MaxAttempts is the number of redial attempts before the Gateway gives
+	// up and reports the connection as lost. Zero means no limit.
+	MaxAttempts int
+
+	// ReclaimTimeout bounds every claim request sent to reclaim a session
+	// after reconnecting. Defaults to 10 seconds.
+	ReclaimTimeout time.Duration
+
+	// OnDisconnect, if set, is called when the connection drops, before the
+	// first redial attempt. Pending requests have already failed with an
+	// error wrapping ErrConnectionClosed.
+	OnDisconnect func(err error)
+
+	// OnReconnect, if set, is called once the connection has been
+	// re-established and every known session has been claimed or dropped.
+	OnReconnect func()
+
+	// OnReclaimFailed, if set, is called for every session the Gateway
+	// refused to hand over to the new connection, usually because the
+	// server's reclaim timeout has expired. The session has already been
+	// removed from the Gateway, it is gone and its Events channels are
+	// closed.
+	OnReclaimFailed func(session *Session, err error)
+}
+
+// WithReconnect makes the Gateway redial the server with exponential backoff
+// when the connection drops, and claim every session of SessionsSnapshot on
+// the new connection, so existing Session and Handle objects keep working.
+// Janus only allows claiming sessions within its configured
+// reclaim_session_timeout.
+func WithReconnect(config ReconnectConfig) Option {
+	if config.MinBackoff <= 0 {
+		config.MinBackoff = time.Second
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = 30 * time.Second
+	}
+	if config.MaxBackoff < config.MinBackoff {
+		config.MaxBackoff = config.MinBackoff
+	}
+	if config.ReclaimTimeout <= 0 {
+		config.ReclaimTimeout = 10 * time.Second
+	}
+	return func(o *options) {
+		o.reconnect = &config
+	}
+}
+
+// WithKeepAlive makes Create start a keepalive loop for every new session,
+// sending a keepalive request each interval until the session is destroyed.
+// If interval is zero it is derived from the session-timeout reported by the
+// server's info response, or 30 seconds if the server does not answer. Failed keepalives are reported as *KeepAliveError
+// on Session.Events.
+func WithKeepAlive(interval time.Duration) Option {
+	return func(o *options) {
+		o.keepAlive = true
+		o.keepAliveInterval = interval
+	}
+}
+
+// WithDialer sets the dialer used by the WebSocket transport. The dialer is
+// copied, the janus-protocol subprotocol and the other connect options are
+// applied to the copy. Defaults to websocket.DefaultDialer.
+func WithDialer(dialer *websocket.Dialer) Option {
+	return func(o *options) {
+		o.dialer = dialer
+	}
+}
+
+// WithTLSConfig sets the TLS configuration of wss:// and https://
+// connections, e.g. to present a client certificate or trust a custom CA.
+func WithTLSConfig(config *tls.Config) Option {
+	return func(o *options) {
+		o.tlsConfig = config
+	}
+}
+
+// WithHeader sets headers sent with the WebSocket handshake, or with every
+// request of the HTTP transport, e.g. Authorization or Origin.
+func WithHeader(header http.Header) Option {
+	return func(o *options) {
+		o.header = header
+	}
+}
+
+// WithProxy sets the function returning the proxy for WebSocket and HTTP
+// connections, see http.ProxyFromEnvironment and http.ProxyURL.
+func WithProxy(proxy func(*http.Request) (*url.URL, error)) Option {
+	return func(o *options) {
+		o.proxy = proxy
+	}
+}
+
+// WithHandshakeTimeout bounds the WebSocket handshake and the TLS handshake
+// of the HTTP transport.
+func WithHandshakeTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.handshakeTimeout = timeout
+	}
+}
+
+// WithReadLimit sets the maximum size in bytes of a frame read by the
+// WebSocket transport. Larger frames fail the connection.
+func WithReadLimit(limit int64) Option {
+	return func(o *options) {
+		o.readLimit = limit
+	}
+}
+
+// WithPingInterval sets the interval of the liveness checks sent through
+// Transport.Ping, zero or a negative interval disables them. Defaults to 30
+// seconds.
+func WithPingInterval(interval time.Duration) Option {
+	return func(o *options) {
+		o.pingInterval = interval
+	}
+}
+
+// WithWriteTimeout bounds every write to the WebSocket and Unix Sockets
+// transports, so a stalled connection fails instead of holding up the
+// requests queued behind it. Defaults to 10 seconds, zero disables it.
+func WithWriteTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.writeTimeout = timeout
+	}
+}
+
+// WithLogger sets the Logger receiving diagnostic messages of the Gateway
+// and its Transport. Defaults to NopLogger.
+func WithLogger(logger Logger) Option {
+	return func(o *options) {
+		if logger == nil {
+			logger = NopLogger{}
+		}
+		o.logger = logger
+	}
+}
+
+// WithEventQueue sets the number of events queued for every Handle and
+// Session while the consumer is not reading its Events channel, and what
+// happens when the queue is full. Defaults to 64 events with
+// OverflowDropOldest.
+func WithEventQueue(size int, policy OverflowPolicy) Option {
+	return func(o *options) {
+		if size > 0 {
+			o.eventQueueSize = size
+		}
+		o.overflowPolicy = policy
+	}
+}
+
+// WithSendQueue sets the number of frames waiting to be written to the
+// connection. Requests sent while the queue is full fail with
+// ErrSendQueueFull. Defaults to 100 frames.
+func WithSendQueue(size int) Option {
+	return func(o *options) {
+		if size > 0 {
+			o.sendQueueSize = size
+		}
+	}
+}
+
+// WithRecorder captures every frame sent and received by the Gateway, across
+// reconnects, with rec. See RecordTransport. The token and apisecret fields
+// of the frames are recorded as Redacted.
+func WithRecorder(rec *Recorder) Option {
+	return func(o *options) {
+		o.recorder = rec
+	}
+}
@@ -50,7 +50,11 @@ func (f *PluginRequestFactory) make(action string) BasePluginRequest {
 	}
 }
 
+// PluginError is an error reported by a plugin. Plugin is not part of the
+// response, it is filled in by the plugin specific error types to tell
+// apart the overlapping code tables of different plugins.
 type PluginError struct {
+	Plugin string `json:"-"`
 	Code   int    `json:"error_code"`
 	Reason string `json:"error"`
 }
@@ -59,15 +63,26 @@ func (err *PluginError) Error() string {
 	return err.Reason
 }
 
+// Is reports whether target is a *PluginError with the same Code, and the
+// same Plugin if both are known, which makes errors.Is match the plugin
+// sentinel errors such as ErrVideoroomNoSuchRoom.
+func (err *PluginError) Is(target error) bool {
+	t, ok := target.(*PluginError)
+	if !ok || t.Code != err.Code {
+		return false
+	}
+	return t.Plugin == "" || err.Plugin == "" || t.Plugin == err.Plugin
+}
+
 var TypeMap = map[string]map[string]func() interface{}{
-	"janus.plugin.videoroom": {
+	VideoroomPluginName: {
 		"error":   func() interface{} { return &VideoroomErrorResponse{} },
 		"list":    func() interface{} { return &VideoroomListResponse{} },
 		"create":  func() interface{} { return &VideoroomCreateResponse{} },
 		"edit":    func() interface{} { return &VideoroomEditResponse{} },
 		"destroy": func() interface{} { return &VideoroomDestroyResponse{} },
 	},
-	"janus.plugin.textroom": {
+	TextroomPluginName: {
 		"error":   func() interface{} { return &TextroomErrorResponse{} },
 		"list":    func() interface{} { return &TextroomListResponse{} },
 		"create":  func() interface{} { return &TextroomCreateResponse{} },
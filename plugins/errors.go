@@ -0,0 +1,110 @@
+package plugins
+
+import "github.com/timsolov/janus-go"
+
+const (
+	VideoroomPluginName = "janus.plugin.videoroom"
+	TextroomPluginName  = "janus.plugin.textroom"
+)
+
+// Error codes of the VideoRoom plugin
+const (
+	VideoroomCodeNoMessage        = 421
+	VideoroomCodeInvalidJSON      = 422
+	VideoroomCodeInvalidRequest   = 423
+	VideoroomCodeJoinFirst        = 424
+	VideoroomCodeAlreadyJoined    = 425
+	VideoroomCodeNoSuchRoom       = 426
+	VideoroomCodeRoomExists       = 427
+	VideoroomCodeNoSuchFeed       = 428
+	VideoroomCodeMissingElement   = 429
+	VideoroomCodeInvalidElement   = 430
+	VideoroomCodeInvalidSDPType   = 431
+	VideoroomCodePublishersFull   = 432
+	VideoroomCodeUnauthorized     = 433
+	VideoroomCodeAlreadyPublished = 434
+	VideoroomCodeNotPublished     = 435
+	VideoroomCodeIDExists         = 436
+	VideoroomCodeInvalidSDP       = 437
+	VideoroomCodeUnknown          = 499
+)
+
+// Sentinel errors of the VideoRoom plugin, matched by errors.Is on
+// *VideoroomErrorResponse and on errors returned by ErrorFromPluginData
+var (
+	ErrVideoroomNoMessage        = videoroomError(VideoroomCodeNoMessage, "No message")
+	ErrVideoroomInvalidJSON      = videoroomError(VideoroomCodeInvalidJSON, "Invalid JSON")
+	ErrVideoroomInvalidRequest   = videoroomError(VideoroomCodeInvalidRequest, "Invalid request")
+	ErrVideoroomJoinFirst        = videoroomError(VideoroomCodeJoinFirst, "Join first")
+	ErrVideoroomAlreadyJoined    = videoroomError(VideoroomCodeAlreadyJoined, "Already joined")
+	ErrVideoroomNoSuchRoom       = videoroomError(VideoroomCodeNoSuchRoom, "No such room")
+	ErrVideoroomRoomExists       = videoroomError(VideoroomCodeRoomExists, "Room exists")
+	ErrVideoroomNoSuchFeed       = videoroomError(VideoroomCodeNoSuchFeed, "No such feed")
+	ErrVideoroomMissingElement   = videoroomError(VideoroomCodeMissingElement, "Missing element")
+	ErrVideoroomInvalidElement   = videoroomError(VideoroomCodeInvalidElement, "Invalid element")
+	ErrVideoroomInvalidSDPType   = videoroomError(VideoroomCodeInvalidSDPType, "Invalid SDP type")
+	ErrVideoroomPublishersFull   = videoroomError(VideoroomCodePublishersFull, "Maximum number of publishers reached")
+	ErrVideoroomUnauthorized     = videoroomError(VideoroomCodeUnauthorized, "Unauthorized")
+	ErrVideoroomAlreadyPublished = videoroomError(VideoroomCodeAlreadyPublished, "Already published")
+	ErrVideoroomNotPublished     = videoroomError(VideoroomCodeNotPublished, "Not published")
+	ErrVideoroomIDExists         = videoroomError(VideoroomCodeIDExists, "ID exists")
+	ErrVideoroomInvalidSDP       = videoroomError(VideoroomCodeInvalidSDP, "Invalid SDP")
+	ErrVideoroomUnknown          = videoroomError(VideoroomCodeUnknown, "Unknown error")
+)
+
+// Error codes of the TextRoom plugin
+const (
+	TextroomCodeNoMessage      = 411
+	TextroomCodeInvalidJSON    = 412
+	TextroomCodeMissingElement = 413
+	TextroomCodeInvalidElement = 414
+	TextroomCodeInvalidRequest = 415
+	TextroomCodeAlreadySetup   = 416
+	TextroomCodeNoSuchRoom     = 417
+	TextroomCodeRoomExists     = 418
+	TextroomCodeUnauthorized   = 419
+	TextroomCodeUsernameExists = 420
+	TextroomCodeAlreadyInRoom  = 421
+	TextroomCodeNotInRoom      = 422
+	TextroomCodeNoSuchUser     = 423
+	TextroomCodeUnknown        = 499
+)
+
+// Sentinel errors of the TextRoom plugin, matched by errors.Is on
+// *TextroomErrorResponse and on errors returned by ErrorFromPluginData
+var (
+	ErrTextroomNoMessage      = textroomError(TextroomCodeNoMessage, "No message")
+	ErrTextroomInvalidJSON    = textroomError(TextroomCodeInvalidJSON, "Invalid JSON")
+	ErrTextroomMissingElement = textroomError(TextroomCodeMissingElement, "Missing element")
+	ErrTextroomInvalidElement = textroomError(TextroomCodeInvalidElement, "Invalid element")
+	ErrTextroomInvalidRequest = textroomError(TextroomCodeInvalidRequest, "Invalid request")
+	ErrTextroomAlreadySetup   = textroomError(TextroomCodeAlreadySetup, "Already setup")
+	ErrTextroomNoSuchRoom     = textroomError(TextroomCodeNoSuchRoom, "No such room")
+	ErrTextroomRoomExists     = textroomError(TextroomCodeRoomExists, "Room exists")
+	ErrTextroomUnauthorized   = textroomError(TextroomCodeUnauthorized, "Unauthorized")
+	ErrTextroomUsernameExists = textroomError(TextroomCodeUsernameExists, "Username exists")
+	ErrTextroomAlreadyInRoom  = textroomError(TextroomCodeAlreadyInRoom, "Already in room")
+	ErrTextroomNotInRoom      = textroomError(TextroomCodeNotInRoom, "Not in room")
+	ErrTextroomNoSuchUser     = textroomError(TextroomCodeNoSuchUser, "No such user")
+	ErrTextroomUnknown        = textroomError(TextroomCodeUnknown, "Unknown error")
+)
+
+func videoroomError(code int, reason string) *PluginError {
+	return &PluginError{Plugin: VideoroomPluginName, Code: code, Reason: reason}
+}
+
+func textroomError(code int, reason string) *PluginError {
+	return &PluginError{Plugin: TextroomPluginName, Code: code, Reason: reason}
+}
+
+// ErrorFromPluginData returns the *PluginError reported in the plugin data of
+// a response or event received through a Handle, or nil if the plugin did
+// not report an error.
+func ErrorFromPluginData(data janus.PluginData) error {
+	code, ok := data.Data["error_code"].(float64)
+	if !ok {
+		return nil
+	}
+	reason, _ := data.Data["error"].(string)
+	return &PluginError{Plugin: data.Plugin, Code: int(code), Reason: reason}
+}
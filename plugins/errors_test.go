@@ -0,0 +1,50 @@
+package plugins
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/timsolov/janus-go"
+)
+
+func TestVideoroomErrorResponse_Is(t *testing.T) {
+	var resp VideoroomErrorResponse
+	err := json.Unmarshal([]byte(`{"videoroom":"event","error_code":426,"error":"No such room (1234)"}`), &resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !errors.Is(&resp, ErrVideoroomNoSuchRoom) {
+		t.Error("expected ErrVideoroomNoSuchRoom to match")
+	}
+	if errors.Is(&resp, ErrVideoroomUnauthorized) {
+		t.Error("unexpected match of ErrVideoroomUnauthorized")
+	}
+
+	var pluginErr *PluginError
+	if !errors.As(&resp, &pluginErr) {
+		t.Fatal("expected errors.As to find a *PluginError")
+	}
+	if pluginErr.Plugin != VideoroomPluginName || pluginErr.Reason != "No such room (1234)" {
+		t.Errorf("unexpected plugin error %+v", pluginErr)
+	}
+}
+
+func TestErrorFromPluginData(t *testing.T) {
+	// 421 is "no message" for videoroom but "already in room" for textroom
+	err := ErrorFromPluginData(janus.PluginData{
+		Plugin: TextroomPluginName,
+		Data:   map[string]interface{}{"textroom": "event", "error_code": float64(421), "error": "Already in room"},
+	})
+	if !errors.Is(err, ErrTextroomAlreadyInRoom) {
+		t.Errorf("expected ErrTextroomAlreadyInRoom, got %v", err)
+	}
+	if errors.Is(err, ErrVideoroomNoMessage) {
+		t.Error("textroom error matched a videoroom sentinel")
+	}
+
+	if err := ErrorFromPluginData(janus.PluginData{Plugin: TextroomPluginName, Data: map[string]interface{}{"textroom": "success"}}); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
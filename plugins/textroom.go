@@ -28,13 +28,33 @@ func (err *TextroomErrorResponse) Error() string {
 	return err.PluginError.Error()
 }
 
+// Is matches the textroom sentinel errors, e.g. ErrTextroomNoSuchRoom
+func (err *TextroomErrorResponse) Is(target error) bool {
+	return err.pluginError().Is(target)
+}
+
+// As sets target to the underlying *PluginError
+func (err *TextroomErrorResponse) As(target interface{}) bool {
+	if t, ok := target.(**PluginError); ok {
+		*t = err.pluginError()
+		return true
+	}
+	return false
+}
+
+func (err *TextroomErrorResponse) pluginError() *PluginError {
+	e := err.PluginError
+	e.Plugin = TextroomPluginName
+	return &e
+}
+
 type TextroomRequestFactory struct {
 	PluginRequestFactory
 }
 
 func MakeTextroomRequestFactory(adminKey string) *TextroomRequestFactory {
 	return &TextroomRequestFactory{
-		PluginRequestFactory: *NewPluginRequestFactory("janus.plugin.textroom", adminKey),
+		PluginRequestFactory: *NewPluginRequestFactory(TextroomPluginName, adminKey),
 	}
 }
 
@@ -19,6 +19,26 @@ func (err *VideoroomErrorResponse) Error() string {
 	return err.PluginError.Error()
 }
 
+// Is matches the videoroom sentinel errors, e.g. ErrVideoroomNoSuchRoom
+func (err *VideoroomErrorResponse) Is(target error) bool {
+	return err.pluginError().Is(target)
+}
+
+// As sets target to the underlying *PluginError
+func (err *VideoroomErrorResponse) As(target interface{}) bool {
+	if t, ok := target.(**PluginError); ok {
+		*t = err.pluginError()
+		return true
+	}
+	return false
+}
+
+func (err *VideoroomErrorResponse) pluginError() *PluginError {
+	e := err.PluginError
+	e.Plugin = VideoroomPluginName
+	return &e
+}
+
 // VideoroomListResponse list of rooms
 type VideoroomListResponse struct {
 	VideoroomResponse
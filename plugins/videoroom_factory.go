@@ -8,7 +8,7 @@ type VideoroomRequestFactory struct {
 // NewVideoroomRequestFactory creates new instance of factory
 func NewVideoroomRequestFactory(adminKey string) *VideoroomRequestFactory {
 	return &VideoroomRequestFactory{
-		PluginRequestFactory: *NewPluginRequestFactory("janus.plugin.videoroom", adminKey),
+		PluginRequestFactory: *NewPluginRequestFactory(VideoroomPluginName, adminKey),
 	}
 }
 
@@ -0,0 +1,325 @@
+package janus
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Directions of a Record.
+const (
+	// RecordSend is a frame sent to the server.
+	RecordSend = "send"
+	// RecordReceive is a frame received from the server.
+	RecordReceive = "recv"
+	// RecordError is a transport failure, its message is in Record.Error.
+	RecordError = "error"
+)
+
+// maxRecordSize is the maximum size of a Record line read by ReadRecords.
+const maxRecordSize = 4 << 20
+
+// Record is a frame captured by a Recorder, stored as one JSON line.
+type Record struct {
+	Time        time.Time       `json:"time"`
+	Direction   string          `json:"direction"`
+	Transaction string          `json:"transaction,omitempty"`
+	Frame       json.RawMessage `json:"frame,omitempty"`
+	Error       string          `json:"error,omitempty"`
+}
+
+// Recorder writes the frames of a Janus connection to w as JSON lines, see
+// RecordTransport. A Recorder may be shared by several transports.
+type Recorder struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewRecorder returns a Recorder writing to w.
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{enc: json.NewEncoder(w)}
+}
+
+// Record writes a frame sent or received in direction. The values of the
+// secret fields of the frame, see Redacted, are replaced by Redacted.
+func (rec *Recorder) Record(direction string, frame []byte) error {
+	var base struct {
+		Transaction string `json:"transaction"`
+	}
+	// Frames that are not JSON are recorded as a JSON string
+	if err := json.Unmarshal(frame, &base); err != nil {
+		frame, _ = json.Marshal(string(frame))
+	} else {
+		frame = redact(frame)
+	}
+	return rec.write(&Record{Direction: direction, Transaction: base.Transaction, Frame: frame})
+}
+
+// Redacted replaces the values of the token, apisecret and admin_secret
+// fields of recorded frames, at any depth, so that recordings can be shared.
+const Redacted = "[redacted]"
+
+// secretFields are the fields whose values are not recorded.
+var secretFields = map[string]bool{"token": true, "apisecret": true, "admin_secret": true}
+
+// redact returns frame with its secrets replaced by Redacted. Frames without
+// secrets are returned as is.
+func redact(frame []byte) []byte {
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(frame))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil || !redactValue(v) {
+		return frame
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return frame
+	}
+	return data
+}
+
+// redactValue replaces the secrets of v in place and reports whether there
+// were any.
+func redactValue(v interface{}) bool {
+	found := false
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if secretFields[key] {
+				v[key] = Redacted
+				found = true
+			} else if redactValue(value) {
+				found = true
+			}
+		}
+	case []interface{}:
+		for _, value := range v {
+			if redactValue(value) {
+				found = true
+			}
+		}
+	}
+	return found
+}
+
+// RecordErr writes a transport failure.
+func (rec *Recorder) RecordErr(err error) error {
+	return rec.write(&Record{Direction: RecordError, Error: err.Error()})
+}
+
+func (rec *Recorder) write(r *Record) error {
+	r.Time = time.Now()
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return rec.enc.Encode(r)
+}
+
+// ReadRecords reads the JSON lines written by a Recorder.
+func ReadRecords(r io.Reader) ([]Record, error) {
+	var records []Record
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxRecordSize)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("line %d: json.Unmarshal: %w", line, err)
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// recordingTransport passes frames through to a Transport, capturing them.
+type recordingTransport struct {
+	Transport
+	rec *Recorder
+}
+
+// RecordTransport returns a Transport capturing every frame sent and
+// received through t, as well as its failures, with rec. Recording errors
+// are ignored. A Gateway connected WithRecorder records its transports this
+// way.
+func RecordTransport(t Transport, rec *Recorder) Transport {
+	return &recordingTransport{Transport: t, rec: rec}
+}
+
+// recordDial wraps the transports returned by dial with RecordTransport.
+func recordDial(dial DialFunc, rec *Recorder) DialFunc {
+	return func() (Transport, error) {
+		t, err := dial()
+		if err != nil {
+			return nil, err
+		}
+		return RecordTransport(t, rec), nil
+	}
+}
+
+func (t *recordingTransport) Send(data []byte) error {
+	t.rec.Record(RecordSend, data)
+	err := t.Transport.Send(data)
+	if err != nil {
+		t.rec.RecordErr(err)
+	}
+	return err
+}
+
+func (t *recordingTransport) Receive() ([]byte, error) {
+	data, err := t.Transport.Receive()
+	if err != nil {
+		t.rec.RecordErr(err)
+		return nil, err
+	}
+	t.rec.Record(RecordReceive, data)
+	return data, nil
+}
+
+// ReplayMismatchError is returned by ReplayTransport.Send when the request
+// sent is not the one that was recorded next.
+type ReplayMismatchError struct {
+	Expected string
+	Got      string
+}
+
+func (err *ReplayMismatchError) Error() string {
+	return fmt.Sprintf("replay: expected '%s' request, got '%s'", err.Expected, err.Got)
+}
+
+// ReplayTransport is a Transport serving recorded frames back to a Gateway.
+// Every received frame is held back until the requests recorded before it
+// have been sent, and transactions are rewritten from the recorded ones to
+// the ones of the requests sent, so a replay does not depend on timing or
+// on the transaction numbering of the recording. Once all frames have been
+// served, Receive blocks until Close. A recording spanning reconnects is
+// replayed by returning the same ReplayTransport from every dial.
+type ReplayTransport struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	events []replayEvent
+	sends  []Record
+	sent   int
+	next   int
+	txns   map[string]string
+	closed bool
+}
+
+// replayEvent is a received frame or failure, available once sent requests
+// have been sent.
+type replayEvent struct {
+	record Record
+	sent   int
+}
+
+// NewReplayTransport returns a ReplayTransport serving records, typically
+// read with ReadRecords.
+func NewReplayTransport(records []Record) *ReplayTransport {
+	t := &ReplayTransport{txns: make(map[string]string)}
+	t.cond = sync.NewCond(&t.mu)
+	for _, record := range records {
+		switch record.Direction {
+		case RecordSend:
+			t.sends = append(t.sends, record)
+		case RecordReceive, RecordError:
+			t.events = append(t.events, replayEvent{record: record, sent: len(t.sends)})
+		}
+	}
+	return t
+}
+
+// Send matches data with the next recorded request.
+func (t *ReplayTransport) Send(data []byte) error {
+	var req struct {
+		Type        string `json:"janus"`
+		Transaction string `json:"transaction"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		return fmt.Errorf("json.Unmarshal: %w", err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return ErrTransportClosed
+	}
+	if t.sent >= len(t.sends) {
+		return &ReplayMismatchError{Got: req.Type}
+	}
+
+	var recorded struct {
+		Type string `json:"janus"`
+	}
+	json.Unmarshal(t.sends[t.sent].Frame, &recorded)
+	if recorded.Type != req.Type {
+		return &ReplayMismatchError{Expected: recorded.Type, Got: req.Type}
+	}
+
+	t.txns[t.sends[t.sent].Transaction] = req.Transaction
+	t.sent++
+	t.cond.Broadcast()
+	return nil
+}
+
+// Receive returns the next recorded frame once the requests recorded
+// before it have been sent.
+func (t *ReplayTransport) Receive() ([]byte, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for !t.closed && (t.next >= len(t.events) || t.events[t.next].sent > t.sent) {
+		t.cond.Wait()
+	}
+	if t.closed {
+		return nil, ErrTransportClosed
+	}
+
+	record := t.events[t.next].record
+	t.next++
+	if record.Direction == RecordError {
+		return nil, fmt.Errorf("replay: %s", record.Error)
+	}
+	return t.rewrite(record), nil
+}
+
+// rewrite replaces the recorded transaction of a frame with the one of the
+// matching request sent.
+func (t *ReplayTransport) rewrite(record Record) []byte {
+	txn, ok := t.txns[record.Transaction]
+	if !ok || record.Transaction == "" {
+		return record.Frame
+	}
+	// Keep large session and handle IDs intact
+	var frame map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(record.Frame))
+	dec.UseNumber()
+	if err := dec.Decode(&frame); err != nil {
+		return record.Frame
+	}
+	frame["transaction"] = txn
+	data, err := json.Marshal(frame)
+	if err != nil {
+		return record.Frame
+	}
+	return data
+}
+
+// Ping does nothing.
+func (t *ReplayTransport) Ping() error {
+	return nil
+}
+
+// Close makes a blocked Receive return ErrTransportClosed.
+func (t *ReplayTransport) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closed = true
+	t.cond.Broadcast()
+	return nil
+}
@@ -0,0 +1,106 @@
+package janus
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/timsolov/janus-go/janustest"
+)
+
+func TestRecordReplay(t *testing.T) {
+	server := janustest.NewServer()
+	defer server.Close()
+
+	var buf bytes.Buffer
+	gateway, err := Connect(server.URL, WithRecorder(NewRecorder(&buf)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, err := gateway.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	handle, err := session.Attach(janustest.VideoroomPluginName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gateway.Close()
+	<-gateway.done
+
+	records, err := ReadRecords(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The connection closed by Close is recorded last
+	if len(records) != 5 || records[4].Direction != RecordError {
+		t.Fatalf("expected 4 frames and an error, got %+v", records)
+	}
+	records = records[:4]
+	for i, direction := range []string{RecordSend, RecordReceive, RecordSend, RecordReceive} {
+		if records[i].Direction != direction || records[i].Transaction == "" || records[i].Time.IsZero() {
+			t.Errorf("unexpected record %d: %+v", i, records[i])
+		}
+	}
+
+	replay := NewReplayTransport(records)
+	gateway, err = ConnectTransport(func() (Transport, error) { return replay, nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gateway.Close()
+
+	// Transactions restart from the same counter, shift them so they differ
+	gateway.nextTransaction = 100
+	replayed, err := gateway.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed.ID != session.ID {
+		t.Errorf("expected session %d, got %d", session.ID, replayed.ID)
+	}
+	replayedHandle, err := replayed.Attach(janustest.VideoroomPluginName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayedHandle.ID != handle.ID {
+		t.Errorf("expected handle %d, got %d", handle.ID, replayedHandle.ID)
+	}
+
+	var mismatch *ReplayMismatchError
+	if _, err := gateway.Info(); !errors.As(err, &mismatch) {
+		t.Errorf("expected ReplayMismatchError, got %v", err)
+	}
+}
+
+func TestRecorder_Redact(t *testing.T) {
+	var buf bytes.Buffer
+	rec := NewRecorder(&buf)
+	frames := []string{
+		`{"janus":"create","transaction":"1","token":"t0ken","apisecret":"s3cret"}`,
+		`{"janus":"list_tokens","transaction":"2","admin_secret":"adm1n"}`,
+		`{"janus":"success","transaction":"2","data":{"tokens":[{"token":"t0ken"}]}}`,
+		`{"janus":"success","transaction":"3","data":{"id":9007199254740993}}`,
+	}
+	for _, frame := range frames {
+		if err := rec.Record(RecordSend, []byte(frame)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, secret := range []string{"t0ken", "s3cret", "adm1n"} {
+		if bytes.Contains(buf.Bytes(), []byte(secret)) {
+			t.Errorf("secret %q recorded: %s", secret, buf.String())
+		}
+	}
+	records, err := ReadRecords(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if records[1].Transaction != "2" {
+		t.Errorf("expected transaction 2, got %q", records[1].Transaction)
+	}
+	if string(records[3].Frame) != frames[3] {
+		t.Errorf("expected %s unchanged, got %s", frames[3], records[3].Frame)
+	}
+}
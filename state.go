@@ -0,0 +1,210 @@
+package janus
+
+import (
+	"sort"
+	"sync/atomic"
+)
+
+// State is the lifecycle state of a Session or Handle.
+type State int32
+
+const (
+	// StateActive is the state of a Session or Handle accepting requests.
+	StateActive State = iota
+	// StateDestroying is the state of a Session being destroyed or of a
+	// Handle being detached. Other requests fail meanwhile, and the state
+	// goes back to StateActive if the destroy or detach request fails.
+	StateDestroying
+	// StateGone is the final state of a Session that was destroyed, timed
+	// out, not reclaimed after a reconnect or whose connection was lost, and
+	// of a Handle that was detached or whose Session is gone.
+	StateGone
+)
+
+func (s State) String() string {
+	switch s {
+	case StateActive:
+		return "active"
+	case StateDestroying:
+		return "destroying"
+	case StateGone:
+		return "gone"
+	}
+	return "unknown"
+}
+
+// Session returns the Session with id, if it is known to the Gateway.
+func (gateway *Gateway) Session(id uint64) (*Session, bool) {
+	gateway.mu.Lock()
+	defer gateway.mu.Unlock()
+	session, ok := gateway.sessions[id]
+	return session, ok
+}
+
+// SessionsSnapshot returns the sessions known to the Gateway, ordered by ID.
+func (gateway *Gateway) SessionsSnapshot() []*Session {
+	gateway.mu.Lock()
+	sessions := make([]*Session, 0, len(gateway.sessions))
+	for _, session := range gateway.sessions {
+		sessions = append(sessions, session)
+	}
+	gateway.mu.Unlock()
+
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].ID < sessions[j].ID })
+	return sessions
+}
+
+// RangeSessions calls f for every session of SessionsSnapshot until f
+// returns false.
+func (gateway *Gateway) RangeSessions(f func(session *Session) bool) {
+	for _, session := range gateway.SessionsSnapshot() {
+		if !f(session) {
+			return
+		}
+	}
+}
+
+// State returns the lifecycle state of the session.
+func (session *Session) State() State {
+	return State(atomic.LoadInt32(&session.state))
+}
+
+// Handle returns the Handle with id, if it is attached to the session.
+func (session *Session) Handle(id uint64) (*Handle, bool) {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	handle, ok := session.handles[id]
+	return handle, ok
+}
+
+// HandlesSnapshot returns the handles attached to the session, ordered by ID.
+func (session *Session) HandlesSnapshot() []*Handle {
+	session.mu.Lock()
+	handles := make([]*Handle, 0, len(session.handles))
+	for _, handle := range session.handles {
+		handles = append(handles, handle)
+	}
+	session.mu.Unlock()
+
+	sort.Slice(handles, func(i, j int) bool { return handles[i].ID < handles[j].ID })
+	return handles
+}
+
+// RangeHandles calls f for every handle of HandlesSnapshot until f returns
+// false.
+func (session *Session) RangeHandles(f func(handle *Handle) bool) {
+	for _, handle := range session.HandlesSnapshot() {
+		if !f(handle) {
+			return
+		}
+	}
+}
+
+// check returns an error unless the session accepts requests.
+func (session *Session) check() error {
+	if session.State() == StateActive {
+		return nil
+	}
+	return session.unavailable()
+}
+
+// unavailable returns the error of a request of a session that does not
+// accept requests.
+func (session *Session) unavailable() error {
+	select {
+	case <-session.gateway.done:
+		return session.gateway.Err()
+	default:
+	}
+	return ErrSessionDestroyed
+}
+
+// release removes the session from the Gateway, marks it and its handles as
+// gone and closes their Events channels.
+func (session *Session) release() {
+	session.gateway.mu.Lock()
+	delete(session.gateway.sessions, session.ID)
+	session.gateway.mu.Unlock()
+
+	atomic.StoreInt32(&session.state, int32(StateGone))
+	session.stopKeepAlive()
+
+	session.mu.Lock()
+	handles := session.handles
+	session.handles = make(map[uint64]*Handle)
+	session.mu.Unlock()
+
+	for _, handle := range handles {
+		atomic.StoreInt32(&handle.state, int32(StateGone))
+		handle.failPending()
+		handle.events.close()
+	}
+	session.events.close()
+}
+
+// State returns the lifecycle state of the handle.
+func (handle *Handle) State() State {
+	return State(atomic.LoadInt32(&handle.state))
+}
+
+// Session returns the session the handle is attached to.
+func (handle *Handle) Session() *Session {
+	return handle.session
+}
+
+// check returns an error unless the handle accepts requests.
+func (handle *Handle) check() error {
+	if handle.State() == StateActive {
+		return nil
+	}
+	return handle.unavailable()
+}
+
+// unavailable returns the error of a request of a handle that does not
+// accept requests.
+func (handle *Handle) unavailable() error {
+	select {
+	case <-handle.session.gateway.done:
+		return handle.session.gateway.Err()
+	default:
+	}
+	return ErrHandleDetached
+}
+
+// release removes the handle from its session, marks it as gone and closes
+// its Events channel.
+func (handle *Handle) release() {
+	handle.session.mu.Lock()
+	delete(handle.session.handles, handle.ID)
+	handle.session.mu.Unlock()
+
+	atomic.StoreInt32(&handle.state, int32(StateGone))
+	handle.failPending()
+	handle.events.close()
+}
+
+// failPending fails the open PendingMessages of a gone handle, so that they
+// do not hold up Gateway.Shutdown.
+func (handle *Handle) failPending() {
+	handle.session.mu.Lock()
+	pending := handle.pending
+	handle.pending = nil
+	handle.session.mu.Unlock()
+
+	for id, message := range pending {
+		handle.session.gateway.forget(id)
+		message.finish(ErrHandleDetached)
+	}
+}
+
+// begin moves state from StateActive to StateDestroying, reporting whether
+// it was active.
+func begin(state *int32) bool {
+	return atomic.CompareAndSwapInt32(state, int32(StateActive), int32(StateDestroying))
+}
+
+// abort moves state back to StateActive after a failed destroy or detach
+// request, unless it is gone meanwhile.
+func abort(state *int32) {
+	atomic.CompareAndSwapInt32(state, int32(StateDestroying), int32(StateActive))
+}
@@ -0,0 +1,112 @@
+package janus
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/timsolov/janus-go/janustest"
+)
+
+func TestSession_Lifecycle(t *testing.T) {
+	server := janustest.NewServer()
+	defer server.Close()
+
+	gateway, err := Connect(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gateway.Close()
+
+	session, err := gateway.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found, ok := gateway.Session(session.ID); !ok || found != session {
+		t.Errorf("session %d not found", session.ID)
+	}
+	first, err := session.Attach(janustest.VideoroomPluginName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := session.Attach(janustest.TextroomPluginName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if handles := session.HandlesSnapshot(); len(handles) != 2 || handles[0] != first || handles[1] != second {
+		t.Errorf("unexpected handles %v", handles)
+	}
+
+	visited := 0
+	session.RangeHandles(func(handle *Handle) bool {
+		visited++
+		return false
+	})
+	if visited != 1 {
+		t.Errorf("expected RangeHandles to stop after 1 handle, visited %d", visited)
+	}
+
+	if _, err := first.Detach(); err != nil {
+		t.Fatal(err)
+	}
+	if first.State() != StateGone {
+		t.Errorf("expected detached handle to be gone, got %s", first.State())
+	}
+	if _, ok := session.Handle(first.ID); ok {
+		t.Error("detached handle still attached")
+	}
+	if _, err := first.Request(nil); !errors.Is(err, ErrHandleDetached) {
+		t.Errorf("expected ErrHandleDetached, got %v", err)
+	}
+	if _, err := first.Detach(); !errors.Is(err, ErrHandleDetached) {
+		t.Errorf("expected ErrHandleDetached, got %v", err)
+	}
+
+	// Janus does not know the session anymore
+	server.Timeout(session.ID)
+	select {
+	case <-session.Events:
+	case <-time.After(time.Second):
+		t.Fatal("no timeout event delivered")
+	}
+	if session.State() != StateGone || second.State() != StateGone {
+		t.Errorf("expected session and handle to be gone, got %s and %s", session.State(), second.State())
+	}
+	if _, err := session.Attach(janustest.VideoroomPluginName); !errors.Is(err, ErrSessionDestroyed) {
+		t.Errorf("expected ErrSessionDestroyed, got %v", err)
+	}
+	if _, err := second.Request(nil); !errors.Is(err, ErrHandleDetached) {
+		t.Errorf("expected ErrHandleDetached, got %v", err)
+	}
+	if len(gateway.SessionsSnapshot()) != 0 {
+		t.Errorf("expected no sessions, got %v", gateway.SessionsSnapshot())
+	}
+}
+
+func TestSession_DestroyFailed(t *testing.T) {
+	server := janustest.NewServer()
+	defer server.Close()
+	server.Handle("destroy", func(req janustest.Request) []janustest.Message {
+		return []janustest.Message{{"janus": "error", "error": map[string]interface{}{"code": 490, "reason": "busy"}}}
+	})
+
+	gateway, err := Connect(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gateway.Close()
+
+	session, err := gateway.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := session.Destroy(); err == nil {
+		t.Fatal("expected destroy to fail")
+	}
+	if session.State() != StateActive {
+		t.Errorf("expected session to be active again, got %s", session.State())
+	}
+	if _, err := session.KeepAlive(); err != nil {
+		t.Error(err)
+	}
+}
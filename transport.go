@@ -0,0 +1,164 @@
+package janus
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Transport carries JSON frames of the Janus API between a Gateway and the
+// server. Implementations only move frames around, all session and handle
+// logic lives in the Gateway.
+type Transport interface {
+	// Send writes a single request frame. Concurrent calls are serialized
+	// by the Gateway.
+	Send(data []byte) error
+
+	// Receive blocks until the next frame arrives from the server. It is
+	// only called from the Gateway's receive loop. Once it returns an error
+	// the Transport is considered dead.
+	Receive() ([]byte, error)
+
+	// Ping checks the liveness of the connection. Transports without a
+	// liveness check return nil.
+	Ping() error
+
+	// Close closes the connection, making a blocked Receive return.
+	Close() error
+}
+
+// DialFunc establishes a new Transport to the server.
+type DialFunc func() (Transport, error)
+
+// ErrTransportClosed is returned by PipeTransport once either end has been
+// closed.
+var ErrTransportClosed = errors.New("janus: transport closed")
+
+func dialURL(url string, o *options) (Transport, error) {
+	var t Transport
+	var err error
+	switch {
+	case strings.HasPrefix(url, "http"):
+		t, err = newHttpTransport(url, o)
+	case strings.HasPrefix(url, "unix://"):
+		t, err = newUnixTransport(strings.TrimPrefix(url, "unix://"), o)
+	default:
+		t, err = newWsTransport(url, o)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// WsTransport implements the Janus WebSocket transport.
+type WsTransport struct {
+	conn         *websocket.Conn
+	writeTimeout time.Duration
+}
+
+// NewWsTransport dials the Janus WebSocket transport at url using the
+// janus-protocol subprotocol. WithDialer, WithTLSConfig, WithHeader,
+// WithProxy, WithHandshakeTimeout, WithReadLimit and WithWriteTimeout apply.
+func NewWsTransport(url string, opts ...Option) (*WsTransport, error) {
+	return newWsTransport(url, newOptions(opts))
+}
+
+func newWsTransport(url string, o *options) (*WsTransport, error) {
+	conn, _, err := o.wsDialer().Dial(url, o.header)
+	if err != nil {
+		return nil, err
+	}
+	if o.readLimit > 0 {
+		conn.SetReadLimit(o.readLimit)
+	}
+
+	return &WsTransport{conn: conn, writeTimeout: o.writeTimeout}, nil
+}
+
+func (t *WsTransport) Send(data []byte) error {
+	if t.writeTimeout > 0 {
+		if err := t.conn.SetWriteDeadline(time.Now().Add(t.writeTimeout)); err != nil {
+			return err
+		}
+	}
+	return t.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+func (t *WsTransport) Receive() ([]byte, error) {
+	_, data, err := t.conn.ReadMessage()
+	return data, err
+}
+
+// Ping sends a WebSocket ping control frame, bounded by the write timeout
+// like Send.
+func (t *WsTransport) Ping() error {
+	var deadline time.Time
+	if t.writeTimeout > 0 {
+		deadline = time.Now().Add(t.writeTimeout)
+	}
+	return t.conn.WriteControl(websocket.PingMessage, []byte{}, deadline)
+}
+
+func (t *WsTransport) Close() error {
+	return t.conn.Close()
+}
+
+// PipeTransport is an in-memory Transport. Frames sent on one end of a pipe
+// are received on the other end, which makes it suitable to connect a
+// Gateway to a fake server in tests.
+type PipeTransport struct {
+	in   chan []byte
+	out  chan []byte
+	done chan struct{}
+	once *sync.Once
+}
+
+// NewPipeTransport returns both ends of an in-memory pipe. Closing either
+// end closes the pipe.
+func NewPipeTransport() (*PipeTransport, *PipeTransport) {
+	a := make(chan []byte)
+	b := make(chan []byte)
+	done := make(chan struct{})
+	once := new(sync.Once)
+	return &PipeTransport{in: a, out: b, done: done, once: once},
+		&PipeTransport{in: b, out: a, done: done, once: once}
+}
+
+func (t *PipeTransport) Send(data []byte) error {
+	select {
+	case t.out <- data:
+		return nil
+	case <-t.done:
+		return ErrTransportClosed
+	}
+}
+
+func (t *PipeTransport) Receive() ([]byte, error) {
+	select {
+	case data := <-t.in:
+		return data, nil
+	case <-t.done:
+		return nil, ErrTransportClosed
+	}
+}
+
+// Ping fails once the pipe has been closed.
+func (t *PipeTransport) Ping() error {
+	select {
+	case <-t.done:
+		return ErrTransportClosed
+	default:
+		return nil
+	}
+}
+
+func (t *PipeTransport) Close() error {
+	t.once.Do(func() {
+		close(t.done)
+	})
+	return nil
+}
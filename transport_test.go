@@ -0,0 +1,118 @@
+package janus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestConnectTransport_Pipe(t *testing.T) {
+	client, server := NewPipeTransport()
+	go func() {
+		for {
+			data, err := server.Receive()
+			if err != nil {
+				return
+			}
+			var req map[string]interface{}
+			if err := json.Unmarshal(data, &req); err != nil {
+				t.Error(err)
+				return
+			}
+			if req["janus"] != "create" {
+				server.Close()
+				return
+			}
+			resp, _ := json.Marshal(map[string]interface{}{
+				"janus":       "success",
+				"transaction": req["transaction"],
+				"data":        map[string]interface{}{"id": 42},
+			})
+			if err := server.Send(resp); err != nil {
+				return
+			}
+		}
+	}()
+
+	dials := 0
+	gateway, err := ConnectTransport(func() (Transport, error) {
+		dials++
+		return client, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gateway.Close()
+
+	session, err := gateway.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.ID != 42 {
+		t.Errorf("expected session 42, got %d", session.ID)
+	}
+
+	// the server closes the pipe on anything but create
+	if _, err = session.KeepAlive(); !errors.Is(err, ErrConnectionClosed) {
+		t.Errorf("expected ErrConnectionClosed, got %v", err)
+	}
+	select {
+	case <-gateway.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Done not closed after the pipe was closed")
+	}
+	if dials != 1 {
+		t.Errorf("expected a single dial, got %d", dials)
+	}
+}
+
+// stalledTransport is a Transport whose writes never complete.
+type stalledTransport struct {
+	*PipeTransport
+	writes chan []byte
+}
+
+func (t *stalledTransport) Send(data []byte) error {
+	t.writes <- data
+	select {}
+}
+
+func TestGateway_SendQueueFull(t *testing.T) {
+	client, server := NewPipeTransport()
+	defer server.Close()
+	transport := &stalledTransport{PipeTransport: client, writes: make(chan []byte, 1)}
+
+	gateway, err := ConnectTransport(func() (Transport, error) {
+		return transport, nil
+	}, WithSendQueue(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gateway.Close()
+
+	go gateway.Info()
+	// The writer is stuck with the first request
+	<-transport.writes
+	go gateway.Info()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	for {
+		gateway.mu.Lock()
+		pending := len(gateway.transactions)
+		gateway.mu.Unlock()
+		if pending == 2 {
+			break
+		}
+		if ctx.Err() != nil {
+			t.Fatal("second request not queued")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, err := gateway.InfoContext(ctx); !errors.Is(err, ErrSendQueueFull) {
+		t.Errorf("expected ErrSendQueueFull, got %v", err)
+	}
+}
@@ -11,11 +11,12 @@
 // The Type field is inspected to determine which concrete type
 // to decode the message to, while the other fields (ID/Session/Handle) are
 // inspected to determine where the message should be delivered. Messages
-// with an ID field defined are considered responses to previous requests, and
-// will be passed directly to requester. Messages without an ID field are
-// considered unsolicited events from the gateway and are expected to have
-// both Session and Handle fields defined. They will be passed to the Events
-// channel of the related Handle and can be read from there.
+// with an ID field of a pending request are considered responses to that
+// request, and will be passed directly to requester. All other messages are
+// considered unsolicited events from the gateway. Events with a Handle field
+// defined are passed to the Events channel of the related Handle, while
+// session wide events such as timeout are passed to the Events channel of
+// the related Session, and can be read from there.
 
 package janus
 
@@ -72,19 +73,24 @@ type SuccessData struct {
 	ID uint64
 }
 
-type DetachedMsg struct{}
+type DetachedMsg struct {
+	Session uint64 `json:"session_id"`
+	Handle  uint64 `json:"sender"`
+}
 
 type InfoMsg struct {
-	Name          string
-	Version       int
-	VersionString string `json:"version_string"`
-	Author        string
-	DataChannels  bool   `json:"data_channels"`
-	IPv6          bool   `json:"ipv6"`
-	LocalIP       string `json:"local-ip"`
-	IceTCP        bool   `json:"ice-tcp"`
-	Transports    map[string]PluginInfo
-	Plugins       map[string]PluginInfo
+	Name                  string
+	Version               int
+	VersionString         string `json:"version_string"`
+	Author                string
+	DataChannels          bool   `json:"data_channels"`
+	IPv6                  bool   `json:"ipv6"`
+	LocalIP               string `json:"local-ip"`
+	IceTCP                bool   `json:"ice-tcp"`
+	SessionTimeout        int    `json:"session-timeout"`
+	ReclaimSessionTimeout int    `json:"reclaim-session-timeout"`
+	Transports            map[string]PluginInfo
+	Plugins               map[string]PluginInfo
 }
 
 type PluginInfo struct {
@@ -119,13 +125,17 @@ type TimeoutMsg struct {
 }
 
 type SlowLinkMsg struct {
-	Uplink bool
-	Lost   int64
+	Uplink  bool
+	Lost    int64
+	Session uint64 `json:"session_id"`
+	Handle  uint64 `json:"sender"`
 }
 
 type MediaMsg struct {
 	Type      string
 	Receiving bool
+	Session   uint64 `json:"session_id"`
+	Handle    uint64 `json:"sender"`
 }
 
 type HangupMsg struct {
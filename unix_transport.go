@@ -0,0 +1,107 @@
+package janus
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// MaxUnixFrameSize is the size of the buffer a single frame is read into
+// from a Unix socket. Larger frames are truncated by the socket.
+const MaxUnixFrameSize = 1 << 20
+
+// UnixTransport implements the Janus Unix Sockets transport, every frame is
+// exchanged as a single packet.
+type UnixTransport struct {
+	conn         net.Conn
+	buf          []byte
+	writeTimeout time.Duration
+}
+
+// ConnectUnix connects to the Janus Unix Sockets transport listening on
+// path. It is a shorthand for Connect("unix://" + path, opts...).
+func ConnectUnix(path string, opts ...Option) (*Gateway, error) {
+	return Connect("unix://"+path, opts...)
+}
+
+// DialUnix dials the Janus Unix Sockets transport listening on path. The
+// SOCK_SEQPACKET flavour is tried first. If the server uses SOCK_DGRAM
+// instead, the returned connection is bound to a temporary socket file in
+// os.TempDir, which is removed when the connection is closed.
+func DialUnix(path string) (net.Conn, error) {
+	conn, err := net.Dial("unixpacket", path)
+	if err == nil || !errors.Is(err, syscall.EPROTOTYPE) {
+		return conn, err
+	}
+
+	local := &net.UnixAddr{
+		Name: filepath.Join(os.TempDir(), fmt.Sprintf("janus-go-%d-%s.sock", os.Getpid(), RandString(8))),
+		Net:  "unixgram",
+	}
+	remote := &net.UnixAddr{Name: path, Net: "unixgram"}
+	dgram, err := net.DialUnix("unixgram", local, remote)
+	if err != nil {
+		return nil, err
+	}
+	return &unlinkConn{UnixConn: dgram, path: local.Name}, nil
+}
+
+// unlinkConn removes the socket file it is bound to when closed.
+type unlinkConn struct {
+	*net.UnixConn
+	path string
+}
+
+func (c *unlinkConn) Close() error {
+	err := c.UnixConn.Close()
+	os.Remove(c.path)
+	return err
+}
+
+// NewUnixTransport connects to the Janus Unix Sockets transport listening on
+// path, see DialUnix. WithWriteTimeout applies.
+func NewUnixTransport(path string, opts ...Option) (*UnixTransport, error) {
+	return newUnixTransport(path, newOptions(opts))
+}
+
+func newUnixTransport(path string, o *options) (*UnixTransport, error) {
+	conn, err := DialUnix(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &UnixTransport{conn: conn, buf: make([]byte, MaxUnixFrameSize), writeTimeout: o.writeTimeout}, nil
+}
+
+func (t *UnixTransport) Send(data []byte) error {
+	if t.writeTimeout > 0 {
+		if err := t.conn.SetWriteDeadline(time.Now().Add(t.writeTimeout)); err != nil {
+			return err
+		}
+	}
+	_, err := t.conn.Write(data)
+	return err
+}
+
+func (t *UnixTransport) Receive() ([]byte, error) {
+	n, err := t.conn.Read(t.buf)
+	if err != nil {
+		return nil, err
+	}
+	frame := make([]byte, n)
+	copy(frame, t.buf[:n])
+	return frame, nil
+}
+
+// Ping is a no-op, a broken socket is detected by Receive.
+func (t *UnixTransport) Ping() error {
+	return nil
+}
+
+func (t *UnixTransport) Close() error {
+	return t.conn.Close()
+}
@@ -0,0 +1,67 @@
+package janus
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConnectUnix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "janus-go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "janus.sock")
+	listener, err := net.Listen("unixpacket", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		buf := make([]byte, 4096)
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				return
+			}
+			var req map[string]interface{}
+			if err := json.Unmarshal(buf[:n], &req); err != nil {
+				t.Error(err)
+				return
+			}
+			resp, _ := json.Marshal(map[string]interface{}{
+				"janus":           "server_info",
+				"transaction":     req["transaction"],
+				"name":            "Janus WebRTC Server",
+				"session-timeout": 60,
+			})
+			conn.Write(resp)
+		}
+	}()
+
+	gateway, err := ConnectUnix(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gateway.Close()
+
+	info, err := gateway.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.SessionTimeout != 60 {
+		t.Errorf("expected session-timeout 60, got %d", info.SessionTimeout)
+	}
+}
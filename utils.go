@@ -4,12 +4,17 @@ import (
 	"encoding/json"
 	"math/rand"
 	"strings"
+	"sync"
 	"time"
 )
 
 // SEE https://stackoverflow.com/questions/22892120/how-to-generate-a-random-string-of-a-fixed-length-in-go
 
-var src = rand.NewSource(time.Now().UnixNano())
+// src is not safe for concurrent use, srcMu guards it
+var (
+	src   = rand.NewSource(time.Now().UnixNano())
+	srcMu sync.Mutex
+)
 
 const letterBytes = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
 const (
@@ -21,6 +26,8 @@ const (
 func RandString(n int) string {
 	sb := strings.Builder{}
 	sb.Grow(n)
+	srcMu.Lock()
+	defer srcMu.Unlock()
 	// A src.Int63() generates 63 random bits, enough for letterIdxMax characters!
 	for i, cache, remain := n-1, src.Int63(), letterIdxMax; i >= 0; {
 		if remain == 0 {